@@ -18,14 +18,18 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/hajimehoshi/gophermv/js"
-	"golang.org/x/net/html"
+	"github.com/hajimehoshi/gophermv/player"
 )
 
 const (
@@ -33,76 +37,232 @@ const (
 	indexHTMLFile  = "index.html"
 )
 
-func process(path string) error {
-	dir := filepath.Dir(path)
-	f, err := os.Open(filepath.Join(dir, indexHTMLFile))
+type screenshot struct {
+	frame int
+	path  string
+}
+
+type screenshotsFlag []*screenshot
+
+func (s *screenshotsFlag) String() string {
+	strs := []string{}
+	for _, ss := range *s {
+		strs = append(strs, fmt.Sprintf("%d:%s", ss.frame, ss.path))
+	}
+	return strings.Join(strs, ",")
+}
+
+func (s *screenshotsFlag) Set(value string) error {
+	tokens := strings.SplitN(value, ":", 2)
+	if len(tokens) != 2 {
+		return fmt.Errorf("invalid screenshot: %s", value)
+	}
+	frame, err := strconv.Atoi(tokens[0])
+	if err != nil {
+		return fmt.Errorf("invalid screenshot frame: %s", tokens[0])
+	}
+	*s = append(*s, &screenshot{
+		frame: frame,
+		path:  tokens[1],
+	})
+	return nil
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	doc, err := html.Parse(f)
-	if err != nil {
+	if err := png.Encode(f, img); err != nil {
 		return err
 	}
-	scriptNodes := []*html.Node{}
-	var walk func(n *html.Node)
-	walk = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "script" {
-			scriptNodes = append(scriptNodes, n)
+	return nil
+}
+
+func process(path string) error {
+	options := &player.Options{
+		Dir:               path,
+		OverridesDir:      *overrides,
+		Transpile:         *transpile,
+		TranspileCacheDir: *transpileCache,
+		NWJS:              *nwjs,
+		AllowedDirs:       allowedDirs,
+	}
+	switch *renderer {
+	case "":
+	case "ebiten":
+		if *headless {
+			// Ebiten images can't be created without ebiten.Run, which needs a window.
+			return fmt.Errorf("-renderer ebiten can't be used with -headless: use -renderer software instead")
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			walk(c)
+		r, err := js.NewEbitenRenderer()
+		if err != nil {
+			return err
 		}
+		options.Renderer = r
+	case "software":
+		options.Renderer = js.NewSoftwareRenderer()
+	default:
+		return fmt.Errorf("not supported renderer: %s", *renderer)
 	}
-	walk(doc)
-	scripts := []string{}
-	for _, n := range scriptNodes {
-		for _, a := range n.Attr {
-			if a.Key != "src" {
-				continue
+	taken := map[*screenshot]struct{}{}
+	if 0 < len(screenshots) {
+		options.FrameSink = func(frame int, screen image.Image) error {
+			for _, s := range screenshots {
+				if s.frame != frame {
+					continue
+				}
+				if err := savePNG(s.path, screen); err != nil {
+					return err
+				}
+				taken[s] = struct{}{}
 			}
-			scripts = append(scripts, a.Val)
+			return nil
 		}
 	}
-	vm, err := js.NewVM(dir)
+	p, err := player.New(options)
 	if err != nil {
 		return err
 	}
-	defer vm.Destroy()
-	for _, s := range scripts {
-		vm.Enqueue(s)
+	defer p.Close()
+	if *seed != 0 {
+		if err := p.SetRandomSeed(*seed); err != nil {
+			return err
+		}
 	}
-	if err := vm.Run(); err != nil {
-		return err
+	if *replay != "" {
+		f, err := os.Open(*replay)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := p.ReplayInput(f); err != nil {
+			return err
+		}
+	}
+	// The recording starts after the replay is loaded so that the seed of the replay is recorded.
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := p.RecordInput(f); err != nil {
+			return err
+		}
+	}
+	if *repl != "" {
+		stop, err := startREPL(p, *repl)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	var runErr error
+	if *headless {
+		runErr = p.RunHeadless(*frames)
+	} else {
+		runErr = p.Run()
+	}
+	if *pluginReport {
+		if err := writePluginReport(os.Stdout, p.Plugins()); err != nil {
+			return err
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+	for _, s := range screenshots {
+		if _, ok := taken[s]; !ok {
+			return fmt.Errorf("screenshot %s was not taken: frame %d was not reached", s.path, s.frame)
+		}
 	}
 	return nil
 }
 
 var (
-	cpuProfile = flag.String("cpuprofile", "", "write cpu profile to file")
+	cpuProfile     = flag.String("cpuprofile", "", "write cpu profile to file")
+	headless       = flag.Bool("headless", false, "run without opening a window")
+	frames         = flag.Int("frames", 60, "number of frames to run in headless mode")
+	renderer       = flag.String("renderer", "", "renderer to draw images: ebiten or software (default ebiten, or software with -headless)")
+	seed           = flag.Int64("seed", 0, "seed for Math.random (0 means Math.random is not seeded)")
+	record         = flag.String("record", "", "record the random seed and key events to file")
+	replay         = flag.String("replay", "", "replay the random seed and key events recorded in file")
+	overrides      = flag.String("overrides", "", "directory of scripts to patch the game's scripts (e.g. js/plugins/Foo.after.js) and .skip files to skip them")
+	pluginReport   = flag.Bool("pluginreport", false, "print which plugins loaded, failed or were skipped when the game ends")
+	repl           = flag.String("repl", "", "evaluate JavaScript lines read from stdin (-), a Unix socket at path or a local TCP address like localhost:9091 between frames")
+	transpile      = flag.Bool("transpile", false, "convert scripts written in ES2015 and later (class, arrow functions, etc.) into ES5 to run them")
+	transpileCache = flag.String("transpilecache", defaultTranspileCache(), "directory to cache scripts converted by -transpile (empty means no cache)")
+	nwjs           = flag.Bool("nwjs", false, "provide nw.js's require('fs'), require('path'), process and nw to scripts; files like save data are written in the game's directory")
 )
 
+func defaultTranspileCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gophermv", "transpile")
+}
+
+var screenshots screenshotsFlag
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+var allowedDirs stringsFlag
+
+func init() {
+	flag.Var(&screenshots, "screenshot", "save the screen at the frame as PNG, in the form of frame:path.png (can be repeated)")
+	flag.Var(&allowedDirs, "allowdir", "directory out of the game that symbolic links in the game can lead to, like a directory of save data (can be repeated)")
+}
+
 var usageTmpl = template.Must(template.New("usage").Parse(
 	`gophermv is a RPG Maker MV player in Go.
 
 Usage:
 
-	gophermv [path/to/{{.RPGProjectFile}}]
+	gophermv [flags] [path]
+	gophermv validate [path]
+
+path is a project directory, a path to {{.RPGProjectFile}} or {{.IndexHTMLFile}},
+or a deployed game directory whose package.json points to www/{{.IndexHTMLFile}}.
+
+Flags:
+
 `))
 
 func printUsage(w io.Writer) {
 	buf := bufio.NewWriter(w)
 	if err := usageTmpl.Execute(buf, struct {
 		RPGProjectFile string
+		IndexHTMLFile  string
 	}{
 		RPGProjectFile: rpgprojectFile,
+		IndexHTMLFile:  indexHTMLFile,
 	}); err != nil {
 		panic(err)
 	}
+	flag.CommandLine.SetOutput(buf)
+	flag.PrintDefaults()
 	buf.Flush()
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command and returns the exit code.
+// run returns instead of calling os.Exit so that the deferred functions are called.
+func run() int {
 	flag.Usage = func() {
 		printUsage(os.Stderr)
 		os.Exit(2)
@@ -113,20 +273,36 @@ func main() {
 		f, err := os.Create(*cpuProfile)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
-			return
+			return 1
 		}
 		if err := pprof.StartCPUProfile(f); err != nil {
 			fmt.Fprintln(os.Stderr, err)
-			return
+			return 1
 		}
 		defer pprof.StopCPUProfile()
 	}
 
+	if flag.Arg(0) == "validate" {
+		arg := flag.Arg(1)
+		if arg == "" {
+			printUsage(os.Stderr)
+			return 2
+		}
+		if err := validate(arg, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
 	arg := flag.Arg(0)
 	if arg == "" {
-		flag.Usage()
+		printUsage(os.Stderr)
+		return 2
 	}
 	if err := process(arg); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,146 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestScreenshotsFlag(t *testing.T) {
+	var s screenshotsFlag
+	for _, v := range []string{"1:a.png", "10:dir/b:c.png"} {
+		if err := s.Set(v); err != nil {
+			t.Fatalf("Set(%q): %v", v, err)
+		}
+	}
+	if got, want := s.String(), "1:a.png,10:dir/b:c.png"; got != want {
+		t.Errorf("String(): got %q, want %q", got, want)
+	}
+	for _, v := range []string{"a.png", "x:a.png", ":a.png"} {
+		if err := s.Set(v); err == nil {
+			t.Errorf("Set(%q) must return an error", v)
+		}
+	}
+	if got, want := len(s), 2; got != want {
+		t.Errorf("len: got %d, want %d", got, want)
+	}
+}
+
+// writeTestGame writes a game that draws a red rectangle at the top-left corner of the screen into a temporary directory.
+func writeTestGame(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"index.html":                 []byte(`<html><body><script type="text/javascript" src="js/main.js"></script></body></html>`),
+		"fonts/mplus-1m-regular.ttf": goregular.TTF,
+		"js/main.js": []byte(`var screen = document.createElement('canvas');
+screen.width = 16;
+screen.height = 16;
+var context = screen.getContext('2d');
+context.fillStyle = '#ff0000';
+context.fillRect(0, 0, 8, 8);
+document.body.appendChild(screen);
+`),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// setHeadlessFlags sets the flags to run a game headlessly for frames with screenshots.
+// The flags are restored when the test finishes.
+func setHeadlessFlags(t *testing.T, n int, ss ...string) {
+	t.Helper()
+	origHeadless, origFrames, origScreenshots := *headless, *frames, screenshots
+	t.Cleanup(func() {
+		*headless, *frames, screenshots = origHeadless, origFrames, origScreenshots
+	})
+	*headless = true
+	*frames = n
+	screenshots = nil
+	for _, s := range ss {
+		if err := screenshots.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessScreenshot(t *testing.T) {
+	game := writeTestGame(t)
+	out := filepath.Join(t.TempDir(), "frame1.png")
+	setHeadlessFlags(t, 3, "1:"+out)
+	if err := process(game); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 816, 624); got != want {
+		t.Errorf("bounds: got %v, want %v", got, want)
+	}
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != red {
+		t.Errorf("At(0, 0): got %v, want %v", got, red)
+	}
+	if got := color.NRGBAModel.Convert(img.At(8, 8)); got == red {
+		t.Errorf("At(8, 8): got %v, want not %v", got, red)
+	}
+}
+
+func TestProcessScreenshotNotReached(t *testing.T) {
+	game := writeTestGame(t)
+	dir := t.TempDir()
+	setHeadlessFlags(t, 3, "2:"+filepath.Join(dir, "frame2.png"), "3:"+filepath.Join(dir, "frame3.png"))
+	err := process(game)
+	if err == nil {
+		t.Fatal("process must return an error")
+	}
+	if !strings.Contains(err.Error(), "frame 3 was not reached") {
+		t.Errorf("error: got %q, want an error about frame 3", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "frame2.png")); err != nil {
+		t.Errorf("the screenshot at frame 2 must be saved: %v", err)
+	}
+}
+
+func TestProcessScreenshotWriteError(t *testing.T) {
+	game := writeTestGame(t)
+	setHeadlessFlags(t, 3, "0:"+filepath.Join(t.TempDir(), "missing", "frame0.png"))
+	if err := process(game); err == nil {
+		t.Error("process must return an error when the screenshot can't be written")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hajimehoshi/gophermv/js"
+)
+
+// writePluginReport writes the status of each plugin.
+func writePluginReport(w io.Writer, plugins []*js.Plugin) error {
+	counts := map[js.PluginStatus]int{}
+	for _, p := range plugins {
+		counts[p.Status]++
+	}
+	if _, err := fmt.Fprintf(w, "plugins: %d loaded, %d failed, %d skipped, %d not loaded\n",
+		counts[js.PluginLoaded], counts[js.PluginFailed], counts[js.PluginSkipped], counts[js.PluginNotLoaded]); err != nil {
+		return err
+	}
+	for _, p := range plugins {
+		line := fmt.Sprintf("%-10s  %s", p.Status, p.Name)
+		switch {
+		case p.Status == js.PluginFailed:
+			// Only the first line of the error report.
+			msg := strings.SplitN(p.Err.Error(), "\n", 2)[0]
+			if jerr, ok := p.Err.(*js.Error); ok && jerr.FileName != "" {
+				msg = fmt.Sprintf("%s (%s:%d)", msg, jerr.FileName, jerr.Line)
+			}
+			line += ": " + msg
+		case p.Status == js.PluginSkipped && !p.Enabled:
+			line += " (disabled)"
+		case p.Status == js.PluginSkipped:
+			line += " (overrides)"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/gophermv/js"
+	"github.com/hajimehoshi/gophermv/player"
+)
+
+// serveREPL evaluates each line read from r and writes the result as JSON to w.
+// An error is written as a JSON object like {"error": "..."}.
+func serveREPL(p *player.Player, r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	for {
+		if _, err := fmt.Fprint(w, "> "); err != nil {
+			return err
+		}
+		if !s.Scan() {
+			break
+		}
+		src := strings.TrimSpace(s.Text())
+		if src == "" {
+			continue
+		}
+		result, err := p.Eval(src)
+		if err != nil {
+			msg := err.Error()
+			if jerr, ok := err.(*js.Error); ok {
+				msg = jerr.Type + ": " + jerr.Message
+			}
+			b, err := json.Marshal(map[string]string{"error": msg})
+			if err != nil {
+				return err
+			}
+			result = string(b)
+		}
+		if _, err := fmt.Fprintln(w, result); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// replListener listens on addr, which is a local TCP address like localhost:9091 or the path of a Unix socket.
+// As the REPL can run any code in the game, a TCP address must be a loopback address.
+func replListener(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || strings.ContainsRune(addr, '/') {
+		return net.Listen("unix", addr)
+	}
+	if host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("REPL address %s is not a loopback address", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+// startREPL starts the REPL reading from stdin if addr is "-", or from connections to addr.
+// See replListener for addr. The returned function stops the REPL.
+func startREPL(p *player.Player, addr string) (func(), error) {
+	if addr == "-" {
+		go func() {
+			if err := serveREPL(p, os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+		return func() {}, nil
+	}
+	l, err := replListener(addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := serveREPL(p, conn, conn); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}()
+		}
+	}()
+	return func() {
+		l.Close()
+	}, nil
+}
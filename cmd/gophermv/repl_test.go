@@ -0,0 +1,51 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestREPLListener(t *testing.T) {
+	testCases := []struct {
+		addr    string
+		network string
+	}{
+		{addr: "localhost:0", network: "tcp"},
+		{addr: "127.0.0.1:0", network: "tcp"},
+		{addr: "0.0.0.0:0"},
+		{addr: "example.com:9091"},
+		{addr: filepath.Join(t.TempDir(), "repl.sock"), network: "unix"},
+	}
+	for _, tc := range testCases {
+		l, err := replListener(tc.addr)
+		if tc.network == "" {
+			if err == nil {
+				l.Close()
+				t.Errorf("replListener(%q) must fail", tc.addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("replListener(%q): %v", tc.addr, err)
+			continue
+		}
+		if got := l.Addr().Network(); got != tc.network {
+			t.Errorf("replListener(%q): got %s, want %s", tc.addr, got, tc.network)
+		}
+		l.Close()
+	}
+}
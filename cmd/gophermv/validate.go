@@ -0,0 +1,383 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/gophermv/js"
+)
+
+type validator struct {
+	fs       fs.FS
+	problems []string
+	data     map[string]interface{}
+
+	// encryptedImages and encryptedAudio are hasEncryptedImages and hasEncryptedAudio in System.json.
+	encryptedImages bool
+	encryptedAudio  bool
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	p := fmt.Sprintf(format, args...)
+	for _, p2 := range v.problems {
+		if p == p2 {
+			return
+		}
+	}
+	v.problems = append(v.problems, p)
+}
+
+// exists reports whether the file exists. An encrypted file like .rpgmvp is also accepted
+// if System.json says that the images or the audio are encrypted.
+func (v *validator) exists(name string) bool {
+	name = path.Clean(name)
+	if _, err := fs.Stat(v.fs, name); err == nil {
+		return true
+	}
+	if path.Ext(name) == ".png" && !v.encryptedImages {
+		return false
+	}
+	if path.Ext(name) != ".png" && !v.encryptedAudio {
+		return false
+	}
+	enc, ok := js.EncryptedName(name)
+	if !ok {
+		return false
+	}
+	_, err := fs.Stat(v.fs, enc)
+	return err == nil
+}
+
+func (v *validator) checkFile(path string, from string) {
+	if v.exists(path) {
+		return
+	}
+	v.errorf("%s: file not found: %s", from, path)
+}
+
+func (v *validator) checkImage(dir, name string, from string) {
+	if name == "" {
+		return
+	}
+	v.checkFile("img/"+dir+"/"+name+".png", from)
+}
+
+func (v *validator) checkAudio(dir string, audio interface{}, from string) {
+	name := str(audio, "name")
+	if name == "" {
+		return
+	}
+	path := "audio/" + dir + "/" + name
+	if v.exists(path+".ogg") || v.exists(path+".m4a") {
+		return
+	}
+	v.errorf("%s: file not found: %s.ogg or %s.m4a", from, path, path)
+}
+
+func prop(obj interface{}, key string) interface{} {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return m[key]
+}
+
+func str(obj interface{}, key string) string {
+	s, _ := prop(obj, key).(string)
+	return s
+}
+
+func arr(obj interface{}) []interface{} {
+	a, _ := obj.([]interface{})
+	return a
+}
+
+func (v *validator) checkScripts() {
+	f, err := v.fs.Open(indexHTMLFile)
+	if err != nil {
+		v.errorf("%v", err)
+		return
+	}
+	defer f.Close()
+	scripts, err := js.ParseScripts(f)
+	if err != nil {
+		v.errorf("%s: %v", indexHTMLFile, err)
+		return
+	}
+	for _, s := range scripts {
+		v.checkFile(s, indexHTMLFile)
+	}
+	v.checkPlugins()
+}
+
+func (v *validator) checkPlugins() {
+	const pluginsFile = "js/plugins.js"
+	b, err := fs.ReadFile(v.fs, pluginsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		v.errorf("%v", err)
+		return
+	}
+	// plugins.js is in the form of 'var $plugins = [...];'.
+	src := string(b)
+	begin := strings.Index(src, "[")
+	end := strings.LastIndex(src, "]")
+	if begin < 0 || end < begin {
+		v.errorf("%s: $plugins is not found", pluginsFile)
+		return
+	}
+	var plugins []interface{}
+	if err := json.Unmarshal([]byte(src[begin:end+1]), &plugins); err != nil {
+		v.errorf("%s: %v", pluginsFile, err)
+		return
+	}
+	for _, p := range plugins {
+		if status, ok := prop(p, "status").(bool); ok && !status {
+			continue
+		}
+		v.checkFile("js/plugins/"+str(p, "name")+".js", pluginsFile)
+	}
+}
+
+func (v *validator) loadData() {
+	v.data = map[string]interface{}{}
+	files, err := fs.Glob(v.fs, "data/*.json")
+	if err != nil {
+		v.errorf("%v", err)
+		return
+	}
+	if len(files) == 0 {
+		v.errorf("data: no JSON files found")
+		return
+	}
+	for _, file := range files {
+		name := "data/" + path.Base(file)
+		b, err := fs.ReadFile(v.fs, file)
+		if err != nil {
+			v.errorf("%v", err)
+			continue
+		}
+		var d interface{}
+		if err := json.Unmarshal(b, &d); err != nil {
+			v.errorf("%s: %v", name, err)
+			continue
+		}
+		v.data[name] = d
+	}
+	if s, ok := v.data["data/System.json"]; ok {
+		v.encryptedImages, _ = prop(s, "hasEncryptedImages").(bool)
+		v.encryptedAudio, _ = prop(s, "hasEncryptedAudio").(bool)
+	}
+}
+
+func (v *validator) checkEventCommands(list interface{}, from string) {
+	for _, c := range arr(list) {
+		params := arr(prop(c, "parameters"))
+		param := func(i int) interface{} {
+			if i < len(params) {
+				return params[i]
+			}
+			return nil
+		}
+		paramStr := func(i int) string {
+			s, _ := param(i).(string)
+			return s
+		}
+		code, _ := prop(c, "code").(float64)
+		switch int(code) {
+		case 101: // Show Text
+			v.checkImage("faces", paramStr(0), from)
+		case 132: // Change Battle BGM
+			v.checkAudio("bgm", param(0), from)
+		case 133, 139: // Change Victory ME, Change Defeat ME
+			v.checkAudio("me", param(0), from)
+		case 140: // Change Vehicle BGM
+			v.checkAudio("bgm", param(1), from)
+		case 205: // Set Movement Route
+			for _, rc := range arr(prop(param(1), "list")) {
+				rparams := arr(prop(rc, "parameters"))
+				rcode, _ := prop(rc, "code").(float64)
+				switch {
+				case rcode == 41 && 0 < len(rparams): // Change Image
+					name, _ := rparams[0].(string)
+					v.checkImage("characters", name, from)
+				case rcode == 44 && 0 < len(rparams): // Play SE
+					v.checkAudio("se", rparams[0], from)
+				}
+			}
+		case 231: // Show Picture
+			v.checkImage("pictures", paramStr(1), from)
+		case 241: // Play BGM
+			v.checkAudio("bgm", param(0), from)
+		case 245: // Play BGS
+			v.checkAudio("bgs", param(0), from)
+		case 249: // Play ME
+			v.checkAudio("me", param(0), from)
+		case 250: // Play SE
+			v.checkAudio("se", param(0), from)
+		case 283: // Change Battle Back
+			v.checkImage("battlebacks1", paramStr(0), from)
+			v.checkImage("battlebacks2", paramStr(1), from)
+		case 284: // Change Parallax
+			v.checkImage("parallaxes", paramStr(0), from)
+		case 322: // Change Actor Images
+			v.checkImage("characters", paramStr(1), from)
+			v.checkImage("faces", paramStr(3), from)
+			v.checkImage("sv_actors", paramStr(5), from)
+		case 323: // Change Vehicle Image
+			v.checkImage("characters", paramStr(1), from)
+		}
+	}
+}
+
+func (v *validator) checkDatabase() {
+	for _, a := range arr(v.data["data/Actors.json"]) {
+		from := "data/Actors.json"
+		v.checkImage("characters", str(a, "characterName"), from)
+		v.checkImage("faces", str(a, "faceName"), from)
+		v.checkImage("sv_actors", str(a, "battlerName"), from)
+	}
+	for _, e := range arr(v.data["data/Enemies.json"]) {
+		name := str(e, "battlerName")
+		if name == "" {
+			continue
+		}
+		if v.exists("img/enemies/"+name+".png") || v.exists("img/sv_enemies/"+name+".png") {
+			continue
+		}
+		v.errorf("data/Enemies.json: file not found: img/enemies/%s.png or img/sv_enemies/%s.png", name, name)
+	}
+	for _, a := range arr(v.data["data/Animations.json"]) {
+		from := "data/Animations.json"
+		v.checkImage("animations", str(a, "animation1Name"), from)
+		v.checkImage("animations", str(a, "animation2Name"), from)
+		for _, t := range arr(prop(a, "timings")) {
+			v.checkAudio("se", prop(t, "se"), from)
+		}
+	}
+	for _, t := range arr(v.data["data/Tilesets.json"]) {
+		for _, name := range arr(prop(t, "tilesetNames")) {
+			name, _ := name.(string)
+			v.checkImage("tilesets", name, "data/Tilesets.json")
+		}
+	}
+	for _, c := range arr(v.data["data/CommonEvents.json"]) {
+		v.checkEventCommands(prop(c, "list"), "data/CommonEvents.json")
+	}
+	for _, t := range arr(v.data["data/Troops.json"]) {
+		for _, p := range arr(prop(t, "pages")) {
+			v.checkEventCommands(prop(p, "list"), "data/Troops.json")
+		}
+	}
+
+	if s, ok := v.data["data/System.json"]; ok {
+		from := "data/System.json"
+		v.checkImage("titles1", str(s, "title1Name"), from)
+		v.checkImage("titles2", str(s, "title2Name"), from)
+		v.checkImage("battlebacks1", str(s, "battleback1Name"), from)
+		v.checkImage("battlebacks2", str(s, "battleback2Name"), from)
+		v.checkAudio("bgm", prop(s, "titleBgm"), from)
+		v.checkAudio("bgm", prop(s, "battleBgm"), from)
+		v.checkAudio("me", prop(s, "victoryMe"), from)
+		v.checkAudio("me", prop(s, "defeatMe"), from)
+		v.checkAudio("me", prop(s, "gameoverMe"), from)
+		for _, se := range arr(prop(s, "sounds")) {
+			v.checkAudio("se", se, from)
+		}
+		for _, vehicle := range []string{"boat", "ship", "airship"} {
+			v.checkImage("characters", str(prop(s, vehicle), "characterName"), from)
+			v.checkAudio("bgm", prop(prop(s, vehicle), "bgm"), from)
+		}
+	} else {
+		v.errorf("data/System.json: file not found")
+	}
+
+	for _, info := range arr(v.data["data/MapInfos.json"]) {
+		id, ok := prop(info, "id").(float64)
+		if !ok {
+			continue
+		}
+		v.checkFile(fmt.Sprintf("data/Map%03d.json", int(id)), "data/MapInfos.json")
+	}
+
+	names := []string{}
+	for name := range v.data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "data/Map") || name == "data/MapInfos.json" {
+			continue
+		}
+		m := v.data[name]
+		v.checkImage("parallaxes", str(m, "parallaxName"), name)
+		v.checkImage("battlebacks1", str(m, "battleback1Name"), name)
+		v.checkImage("battlebacks2", str(m, "battleback2Name"), name)
+		v.checkAudio("bgm", prop(m, "bgm"), name)
+		v.checkAudio("bgs", prop(m, "bgs"), name)
+		for _, e := range arr(prop(m, "events")) {
+			for _, p := range arr(prop(e, "pages")) {
+				v.checkImage("characters", str(prop(p, "image"), "characterName"), name)
+				v.checkEventCommands(prop(p, "list"), name)
+			}
+		}
+	}
+}
+
+// validate checks that the files referenced by the project exist and can be parsed,
+// and writes the problems to w.
+func validate(path string, w io.Writer) error {
+	dir, err := js.ProjectDir(path)
+	if err != nil {
+		return err
+	}
+	sandbox, err := js.NewSandbox(dir, allowedDirs...)
+	if err != nil {
+		return err
+	}
+	fsys, err := js.ProjectFS(sandbox)
+	if err != nil {
+		return err
+	}
+	problems := check(fsys)
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if 0 < len(problems) {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	return nil
+}
+
+// check returns the problems of the game whose files are in fsys.
+func check(fsys fs.FS) []string {
+	v := &validator{
+		fs: fsys,
+	}
+	v.checkScripts()
+	v.loadData()
+	v.checkDatabase()
+	return v.problems
+}
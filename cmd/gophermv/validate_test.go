@@ -0,0 +1,150 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// newTestProject returns the files of a game that has no problems.
+func newTestProject() fstest.MapFS {
+	files := map[string]string{
+		"index.html":                `<html><body><script type="text/javascript" src="js/main.js"></script></body></html>`,
+		"js/main.js":                ``,
+		"js/plugins.js":             `var $plugins = [{"name":"Plugin","status":true,"parameters":{}},{"name":"Disabled","status":false,"parameters":{}}];`,
+		"js/plugins/Plugin.js":      ``,
+		"data/System.json":          `{"title1Name":"Title","titleBgm":{"name":"Theme"},"sounds":[{"name":"Cursor"}]}`,
+		"data/MapInfos.json":        `[null,{"id":1,"name":"MAP001"}]`,
+		"data/Map001.json":          `{"bgm":{"name":"Field"},"events":[null,{"pages":[{"image":{"characterName":"Actor1"},"list":[{"code":101,"parameters":["Face"]},{"code":250,"parameters":[{"name":"Bell"}]},{"code":0,"parameters":[]}]}]}]}`,
+		"data/Tilesets.json":        `[null,{"tilesetNames":["World_A1",""]}]`,
+		"img/titles1/Title.png":     ``,
+		"img/characters/Actor1.png": ``,
+		"img/faces/Face.png":        ``,
+		"img/tilesets/World_A1.png": ``,
+		"audio/bgm/Theme.ogg":       ``,
+		"audio/bgm/Field.ogg":       ``,
+		"audio/se/Cursor.ogg":       ``,
+		"audio/se/Bell.m4a":         ``,
+	}
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+func TestCheck(t *testing.T) {
+	const encryptedSystem = `{"title1Name":"Title","titleBgm":{"name":"Theme"},"sounds":[{"name":"Cursor"}],"hasEncryptedImages":true,"hasEncryptedAudio":true,"encryptionKey":"00"}`
+
+	testCases := []struct {
+		name   string
+		remove []string
+		add    map[string]string
+		want   []string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:   "missing script",
+			remove: []string{"js/main.js"},
+			want:   []string{"index.html: file not found: js/main.js"},
+		},
+		{
+			name:   "missing plugin",
+			remove: []string{"js/plugins/Plugin.js"},
+			want:   []string{"js/plugins.js: file not found: js/plugins/Plugin.js"},
+		},
+		{
+			name:   "missing map",
+			remove: []string{"data/Map001.json"},
+			want:   []string{"data/MapInfos.json: file not found: data/Map001.json"},
+		},
+		{
+			name:   "missing tileset",
+			remove: []string{"img/tilesets/World_A1.png"},
+			want:   []string{"data/Tilesets.json: file not found: img/tilesets/World_A1.png"},
+		},
+		{
+			name:   "missing images",
+			remove: []string{"img/titles1/Title.png", "img/characters/Actor1.png", "img/faces/Face.png"},
+			want: []string{
+				"data/System.json: file not found: img/titles1/Title.png",
+				"data/Map001.json: file not found: img/characters/Actor1.png",
+				"data/Map001.json: file not found: img/faces/Face.png",
+			},
+		},
+		{
+			name:   "missing audio",
+			remove: []string{"audio/bgm/Theme.ogg", "audio/bgm/Field.ogg", "audio/se/Bell.m4a"},
+			want: []string{
+				"data/System.json: file not found: audio/bgm/Theme.ogg or audio/bgm/Theme.m4a",
+				"data/Map001.json: file not found: audio/bgm/Field.ogg or audio/bgm/Field.m4a",
+				"data/Map001.json: file not found: audio/se/Bell.ogg or audio/se/Bell.m4a",
+			},
+		},
+		{
+			name:   "missing System.json",
+			remove: []string{"data/System.json"},
+			want:   []string{"data/System.json: file not found"},
+		},
+		{
+			name: "invalid JSON",
+			add:  map[string]string{"data/Tilesets.json": `[null,`},
+			want: []string{"data/Tilesets.json: unexpected end of JSON input"},
+		},
+		{
+			name:   "encrypted files",
+			remove: []string{"img/titles1/Title.png", "audio/bgm/Theme.ogg"},
+			add: map[string]string{
+				"data/System.json":         encryptedSystem,
+				"img/titles1/Title.rpgmvp": ``,
+				"audio/bgm/Theme.rpgmvo":   ``,
+			},
+		},
+		{
+			name:   "encrypted files in an unencrypted game",
+			remove: []string{"img/titles1/Title.png", "audio/bgm/Theme.ogg"},
+			add: map[string]string{
+				"img/titles1/Title.rpgmvp": ``,
+				"audio/bgm/Theme.rpgmvo":   ``,
+			},
+			want: []string{
+				"data/System.json: file not found: img/titles1/Title.png",
+				"data/System.json: file not found: audio/bgm/Theme.ogg or audio/bgm/Theme.m4a",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := newTestProject()
+			for _, name := range tc.remove {
+				delete(fsys, name)
+			}
+			for name, data := range tc.add {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			got := check(fsys)
+			if len(got) == 0 && len(tc.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
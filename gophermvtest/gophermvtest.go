@@ -0,0 +1,220 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gophermvtest provides utilities for regression tests of RPG Maker MV games
+// with golden images.
+//
+// A typical test runs a scenario and compares the captured frames with golden PNG files:
+//
+//	s := &gophermvtest.Scenario{
+//		Dir:      "testdata/game",
+//		Captures: []int{60},
+//	}
+//	frames, err := s.Run()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	gophermvtest.CheckGolden(t, frames[60], "testdata/title.png", 0)
+package gophermvtest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/gophermv/js"
+	"github.com/hajimehoshi/gophermv/player"
+)
+
+// Update indicates whether CheckGolden overwrites golden files with actual images
+// instead of comparing them.
+//
+// Tests typically set Update by their own flag like -update.
+var Update = false
+
+// Scenario represents a run of a game.
+type Scenario struct {
+	// Dir is the path to the game, which is interpreted in the same way as js.ProjectDir.
+	Dir string
+
+	// FS is the filesystem of the game, which is interpreted in the same way as js.ProjectFS.
+	// If FS is not nil, FS is used instead of Dir. This is useful e.g. for in-memory fixtures.
+	FS fs.FS
+
+	// Seed is the seed for Math.random.
+	Seed int64
+
+	// KeyEvents is the key events dispatched to the game, sorted by frames.
+	KeyEvents []*js.KeyEvent
+
+	// Frames is the number of frames to run.
+	// If Frames is 0, the game runs until the last frame in Captures.
+	Frames int
+
+	// Captures is the frames to capture the screen at.
+	Captures []int
+}
+
+// Run runs the scenario with the software renderer and returns the captured screens keyed by frames.
+func (s *Scenario) Run() (map[int]*image.RGBA, error) {
+	frames := s.Frames
+	captures := map[int]*image.RGBA{}
+	for _, c := range s.Captures {
+		captures[c] = nil
+		if frames <= c && s.Frames == 0 {
+			frames = c + 1
+		}
+	}
+	p, err := player.New(&player.Options{
+		Dir:      s.Dir,
+		FS:       s.FS,
+		Input:    js.NewReplayInput(s.KeyEvents),
+		Renderer: js.NewSoftwareRenderer(),
+		FrameSink: func(frame int, screen image.Image) error {
+			if _, ok := captures[frame]; !ok {
+				return nil
+			}
+			b := screen.Bounds()
+			img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+			draw.Draw(img, img.Bounds(), screen, b.Min, draw.Src)
+			captures[frame] = img
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	if err := p.SetRandomSeed(s.Seed); err != nil {
+		return nil, err
+	}
+	if err := p.RunHeadless(frames); err != nil {
+		return nil, err
+	}
+	for frame, img := range captures {
+		if img == nil {
+			return nil, fmt.Errorf("gophermvtest: frame %d was not reached", frame)
+		}
+	}
+	return captures, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Compare compares two images pixel by pixel.
+//
+// Compare returns the number of pixels that have a channel differing by more than tolerance,
+// and an image where such pixels are red and the others are faded.
+// The sizes of the images must be the same.
+func Compare(got, want image.Image, tolerance int) (int, *image.RGBA) {
+	b := got.Bounds()
+	wb := want.Bounds()
+	diff := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	n := 0
+	for j := 0; j < b.Dy(); j++ {
+		for i := 0; i < b.Dx(); i++ {
+			gc := color.NRGBAModel.Convert(got.At(b.Min.X+i, b.Min.Y+j)).(color.NRGBA)
+			wc := color.NRGBAModel.Convert(want.At(wb.Min.X+i, wb.Min.Y+j)).(color.NRGBA)
+			if tolerance < abs(int(gc.R)-int(wc.R)) ||
+				tolerance < abs(int(gc.G)-int(wc.G)) ||
+				tolerance < abs(int(gc.B)-int(wc.B)) ||
+				tolerance < abs(int(gc.A)-int(wc.A)) {
+				diff.Set(i, j, color.RGBA{0xff, 0, 0, 0xff})
+				n++
+				continue
+			}
+			y := color.GrayModel.Convert(wc).(color.Gray).Y
+			diff.Set(i, j, color.NRGBA{y, y, y, 0x40})
+		}
+	}
+	return n, diff
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+// CheckGolden compares img with the golden PNG file at path with the per-pixel tolerance
+// and reports a failure to t.
+//
+// On failure, the actual image and the diff image are written next to the golden file
+// with the suffixes .got.png and .diff.png.
+// If Update is true, img is written as the golden file instead.
+// A missing golden file is a failure unless Update is true.
+func CheckGolden(t testing.TB, img image.Image, path string, tolerance int) {
+	t.Helper()
+	if Update {
+		if err := writePNG(path, img); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: golden file written", path)
+		return
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	want, err := readPNG(path)
+	if os.IsNotExist(err) {
+		if err := writePNG(base+".got.png", img); err != nil {
+			t.Fatal(err)
+		}
+		t.Errorf("%s: golden missing; set gophermvtest.Update to write it", path)
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Size() != want.Bounds().Size() {
+		if err := writePNG(base+".got.png", img); err != nil {
+			t.Fatal(err)
+		}
+		t.Errorf("%s: size mismatch: got %v, want %v", path, img.Bounds().Size(), want.Bounds().Size())
+		return
+	}
+	n, diff := Compare(img, want, tolerance)
+	if n == 0 {
+		return
+	}
+	if err := writePNG(base+".got.png", img); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePNG(base+".diff.png", diff); err != nil {
+		t.Fatal(err)
+	}
+	t.Errorf("%s: %d pixels differ (tolerance: %d); see %s.diff.png", path, n, tolerance, base)
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gophermvtest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder records failures reported by CheckGolden instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func newTestImage(clr color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			img.Set(i, j, clr)
+		}
+	}
+	return img
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestCheckGoldenMatch(t *testing.T) {
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.png")
+	if err := writePNG(golden, newTestImage(color.White)); err != nil {
+		t.Fatal(err)
+	}
+
+	img := newTestImage(color.White)
+	// A difference within the tolerance is not a failure.
+	img.Set(1, 2, color.RGBA{0xfe, 0xff, 0xff, 0xff})
+	r := &recorder{TB: t}
+	CheckGolden(r, img, golden, 1)
+	if len(r.errors) != 0 {
+		t.Errorf("CheckGolden reported %q", r.errors)
+	}
+	if exists(filepath.Join(dir, "golden.got.png")) || exists(filepath.Join(dir, "golden.diff.png")) {
+		t.Errorf("CheckGolden wrote a .got.png or a .diff.png file for matching images")
+	}
+}
+
+func TestCheckGoldenMismatch(t *testing.T) {
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.png")
+	if err := writePNG(golden, newTestImage(color.White)); err != nil {
+		t.Fatal(err)
+	}
+
+	img := newTestImage(color.White)
+	img.Set(1, 2, color.Black)
+	r := &recorder{TB: t}
+	CheckGolden(r, img, golden, 0)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "1 pixels differ") {
+		t.Errorf("CheckGolden reported %q, want a failure of 1 pixel", r.errors)
+	}
+
+	got, err := readPNG(filepath.Join(dir, "golden.got.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := Compare(got, img, 0); n != 0 {
+		t.Errorf("golden.got.png is not the actual image")
+	}
+	diff, err := readPNG(filepath.Join(dir, "golden.diff.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			c := color.RGBAModel.Convert(diff.At(i, j)).(color.RGBA)
+			isRed := c == color.RGBA{0xff, 0, 0, 0xff}
+			if want := i == 1 && j == 2; isRed != want {
+				t.Errorf("diff at (%d, %d): got %v", i, j, c)
+			}
+		}
+	}
+}
+
+func TestCheckGoldenSizeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.png")
+	if err := writePNG(golden, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{TB: t}
+	CheckGolden(r, newTestImage(color.White), golden, 0)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "size mismatch") {
+		t.Errorf("CheckGolden reported %q, want a size mismatch", r.errors)
+	}
+}
+
+func TestCheckGoldenMissing(t *testing.T) {
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.png")
+
+	r := &recorder{TB: t}
+	CheckGolden(r, newTestImage(color.White), golden, 0)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "gophermvtest.Update") {
+		t.Errorf("CheckGolden reported %q, want a failure suggesting gophermvtest.Update", r.errors)
+	}
+	if exists(golden) {
+		t.Errorf("CheckGolden wrote the golden file without Update")
+	}
+}
+
+func TestCheckGoldenUpdate(t *testing.T) {
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.png")
+	if err := writePNG(golden, newTestImage(color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	Update = true
+	defer func() {
+		Update = false
+	}()
+	img := newTestImage(color.White)
+	r := &recorder{TB: t}
+	CheckGolden(r, img, golden, 0)
+	if len(r.errors) != 0 {
+		t.Errorf("CheckGolden reported %q", r.errors)
+	}
+	want, err := readPNG(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := Compare(img, want, 0); n != 0 {
+		t.Errorf("the golden file is not updated")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"net/url"
+)
+
+// AudioEvent is a request to play or stop audio by the game.
+//
+// gophermv doesn't play audio by itself. Audio requests are passed to the handler set by SetAudioHandler.
+type AudioEvent struct {
+	// Type is "play" or "stop".
+	Type string
+
+	// URL is the path of the audio file with its extension like "audio/bgm/Theme1.ogg",
+	// which can be passed to ReadAsset.
+	// The URL encoding of the game's WebAudio._url is decoded.
+	URL string
+
+	Loop   bool
+	Offset float64
+	Volume float64
+	Pitch  float64
+	Pan    float64
+}
+
+// SetAudioHandler sets a function called when the game plays or stops audio.
+func (vm *VM) SetAudioHandler(f func(e *AudioEvent) error) {
+	vm.audioHandler = f
+}
+
+func jsNotifyAudio(vm *VM) (int, error) {
+	if vm.audioHandler == nil {
+		return 0, nil
+	}
+	u := vm.context.GetString(1)
+	// AudioManager encodes file names by encodeURIComponent.
+	if decoded, err := url.PathUnescape(u); err == nil {
+		u = decoded
+	}
+	e := &AudioEvent{
+		Type:   vm.context.GetString(0),
+		URL:    u,
+		Loop:   vm.context.GetBoolean(2),
+		Offset: vm.context.GetNumber(3),
+		Volume: vm.context.GetNumber(4),
+		Pitch:  vm.context.GetNumber(5),
+		Pan:    vm.context.GetNumber(6),
+	}
+	if err := vm.audioHandler(e); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Register registers the Go function fn as a global JavaScript function name.
+//
+// The arguments and the return value are converted between JavaScript and Go:
+// numbers, strings and booleans are converted directly, *Value is a copy of the value,
+// and the other values like slices, maps and structs are converted as encoding/json does.
+// A missing, undefined or null argument is the zero value, except that a missing *Value
+// argument is an undefined Value.
+//
+// fn can return nothing, a value, an error, or a value and an error.
+// A non-nil error is thrown as a JavaScript Error.
+func (vm *VM) Register(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	t := f.Type()
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("js: Register: %s is not a function: %T", name, fn)
+	}
+	switch t.NumOut() {
+	case 0:
+	case 1:
+	case 2:
+		if t.Out(1) != errorType {
+			return fmt.Errorf("js: Register: the second return value of %s must be an error", name)
+		}
+	default:
+		return fmt.Errorf("js: Register: %s returns too many values", name)
+	}
+	b := &binding{
+		name: name,
+		f:    f,
+	}
+	if _, err := vm.context.PushGlobalGoFunction(name, wrapFunc(b.call, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
+
+type binding struct {
+	name string
+	f    reflect.Value
+}
+
+func (b *binding) call(vm *VM) (int, error) {
+	t := b.f.Type()
+	n := vm.context.GetTop()
+	args := []reflect.Value{}
+	for i := 0; i < t.NumIn() || (t.IsVariadic() && i < n); i++ {
+		var at reflect.Type
+		if t.IsVariadic() && t.NumIn()-1 <= i {
+			if n <= i {
+				break
+			}
+			at = t.In(t.NumIn() - 1).Elem()
+		} else {
+			at = t.In(i)
+		}
+		v, err := vm.getValue(i, at)
+		if err != nil {
+			return 0, fmt.Errorf("js: %s: argument #%d: %v", b.name, i, err)
+		}
+		args = append(args, v)
+	}
+	outs := b.f.Call(args)
+	if 0 < len(outs) && outs[len(outs)-1].Type() == errorType {
+		if err := outs[len(outs)-1]; !err.IsNil() {
+			return 0, err.Interface().(error)
+		}
+		outs = outs[:len(outs)-1]
+	}
+	if len(outs) == 0 {
+		return 0, nil
+	}
+	if err := vm.pushValue(outs[0]); err != nil {
+		return 0, fmt.Errorf("js: %s: return value: %v", b.name, err)
+	}
+	return 1, nil
+}
+
+// getValue converts the JavaScript value at index into a Go value of type t.
+func (vm *VM) getValue(index int, t reflect.Type) (reflect.Value, error) {
+	if t == valueType {
+		if vm.context.GetTop() <= index {
+			return reflect.ValueOf(&Value{undefined: true}), nil
+		}
+		v, err := vm.valueAt(index)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v), nil
+	}
+	v := reflect.New(t).Elem()
+	if vm.context.GetTop() <= index || vm.context.IsNullOrUndefined(index) {
+		return v, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if !vm.context.IsBoolean(index) {
+			return v, fmt.Errorf("boolean expected")
+		}
+		v.SetBool(vm.context.GetBoolean(index))
+		return v, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !vm.context.IsNumber(index) {
+			return v, fmt.Errorf("number expected")
+		}
+		v.SetInt(int64(vm.context.GetNumber(index)))
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !vm.context.IsNumber(index) {
+			return v, fmt.Errorf("number expected")
+		}
+		v.SetUint(uint64(vm.context.GetNumber(index)))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		if !vm.context.IsNumber(index) {
+			return v, fmt.Errorf("number expected")
+		}
+		v.SetFloat(vm.context.GetNumber(index))
+		return v, nil
+	case reflect.String:
+		if !vm.context.IsString(index) {
+			return v, fmt.Errorf("string expected")
+		}
+		v.SetString(vm.context.GetString(index))
+		return v, nil
+	}
+	j, ok, err := vm.stringify(index)
+	if err != nil {
+		return v, err
+	}
+	if !ok {
+		return v, nil
+	}
+	if err := json.Unmarshal([]byte(j), v.Addr().Interface()); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// pushValue pushes the Go value v as a JavaScript value.
+func (vm *VM) pushValue(v reflect.Value) error {
+	if !v.IsValid() {
+		vm.context.PushNull()
+		return nil
+	}
+	if v.Type() == valueType && !v.IsNil() && v.Interface().(*Value).undefined {
+		vm.context.PushUndefined()
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		vm.context.PushBoolean(v.Bool())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		vm.context.PushNumber(float64(v.Int()))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		vm.context.PushNumber(float64(v.Uint()))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		vm.context.PushNumber(v.Float())
+		return nil
+	case reflect.String:
+		vm.context.PushString(v.String())
+		return nil
+	}
+	j, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	vm.context.PushString(string(j))
+	vm.context.JsonDecode(-1)
+	return nil
+}
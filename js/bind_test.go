@@ -0,0 +1,258 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// runTestScript runs src and returns the value of the global variable result as JSON.
+func runTestScript(t *testing.T, vm *VM, src string) string {
+	t.Helper()
+	if err := vm.execSource("test.js", src); err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	v, err := vm.Get("result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.String()
+}
+
+func TestRegister(t *testing.T) {
+	vm := newTestVM(t, nil)
+	if err := vm.Register("describe", func(n int, s string, b bool, v *Value) string {
+		return fmt.Sprintf("%d %q %t %s", n, s, b, v)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Register("sum", func(base float64, xs ...int) float64 {
+		for _, x := range xs {
+			base += float64(x)
+		}
+		return base
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Register("point", func(p struct{ X, Y int }) map[string]int {
+		return map[string]int{"sum": p.X + p.Y}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Register("fail", func(msg string) (int, error) {
+		return 0, errors.New(msg)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Register("nothing", func() {}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "arguments",
+			src:  `var result = describe(1, 'a', true, {x: [1, 2]});`,
+			want: `"1 \"a\" true {\"x\":[1,2]}"`,
+		},
+		{
+			name: "missing arguments",
+			src:  `var result = describe();`,
+			want: `"0 \"\" false undefined"`,
+		},
+		{
+			name: "missing Value argument",
+			src:  `var result = describe(1, 'a', true);`,
+			want: `"1 \"a\" true undefined"`,
+		},
+		{
+			name: "extra arguments",
+			src:  `var result = describe(1, 'a', true, 2, 3, 4);`,
+			want: `"1 \"a\" true 2"`,
+		},
+		{
+			name: "null arguments",
+			src:  `var result = describe(null, null, null, null);`,
+			want: `"0 \"\" false null"`,
+		},
+		{
+			name: "undefined Value argument",
+			src:  `var result = describe(1, 'a', true, undefined);`,
+			want: `"1 \"a\" true undefined"`,
+		},
+		{
+			name: "variadic",
+			src:  `var result = [sum(0.5), sum(0.5, 1, 2, 3)];`,
+			want: `[0.5,6.5]`,
+		},
+		{
+			name: "struct",
+			src:  `var result = point({X: 1, Y: 2}).sum;`,
+			want: `3`,
+		},
+		{
+			name: "error",
+			src:  `var result; try { fail('boom'); } catch (e) { result = e instanceof Error && e.message; }`,
+			want: `"boom"`,
+		},
+		{
+			name: "wrong type",
+			src:  `var result; try { describe('1'); } catch (e) { result = e.message; }`,
+			want: `"js: describe: argument #0: number expected"`,
+		},
+		{
+			name: "no return value",
+			src:  `var result = nothing() === undefined;`,
+			want: `true`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runTestScript(t, vm, tc.src); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	vm := newTestVM(t, nil)
+	for _, fn := range []interface{}{
+		1,
+		func() (int, int) { return 0, 0 },
+		func() (int, error, int) { return 0, nil, 0 },
+	} {
+		if err := vm.Register("f", fn); err == nil {
+			t.Errorf("Register(%T): got nil, want an error", fn)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	testCases := []struct {
+		path string
+		want []interface{}
+	}{
+		{path: "a", want: []interface{}{"a"}},
+		{path: "$gameVariables._data", want: []interface{}{"$gameVariables", "_data"}},
+		{path: "a[1]", want: []interface{}{"a", 1}},
+		{path: "a.b[1][2].c", want: []interface{}{"a", "b", 1, 2, "c"}},
+		{path: ""},
+		{path: "a."},
+		{path: ".a"},
+		{path: "[1]"},
+		{path: "a[x]"},
+		{path: "a[-1]"},
+		{path: "a[1"},
+		{path: "a[1]b"},
+	}
+	for _, tc := range testCases {
+		got, err := parsePath(tc.path)
+		if tc.want == nil {
+			if err == nil {
+				t.Errorf("parsePath(%q): got %v, want an error", tc.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePath(%q): %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parsePath(%q): got %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	vm := newTestVM(t, nil)
+	runTestScript(t, vm, `
+var result = null;
+var data = {a: {b: [1, {c: 'x'}]}, f: function() {}};
+var throwing = {};
+Object.defineProperty(throwing, 'p', {
+  get: function() { throw new Error('get'); },
+  set: function() { throw new Error('set'); },
+});
+`)
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{path: "data.a.b[1].c", want: `"x"`},
+		{path: "data.a.b", want: `[1,{"c":"x"}]`},
+		{path: "data.f", want: "undefined"},
+		{path: "data.nothing", want: "undefined"},
+	} {
+		v, err := vm.Get(tc.path)
+		if err != nil {
+			t.Errorf("Get(%q): %v", tc.path, err)
+			continue
+		}
+		if got := v.String(); got != tc.want {
+			t.Errorf("Get(%q): got %s, want %s", tc.path, got, tc.want)
+		}
+	}
+
+	for _, path := range []string{"data.nothing.x", "data..a", "throwing.p"} {
+		if _, err := vm.Get(path); err == nil {
+			t.Errorf("Get(%q): got nil, want an error", path)
+		}
+	}
+
+	if err := vm.Set("data.a.b[0]", map[string]interface{}{"d": []int{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Set("data.s", "y"); err != nil {
+		t.Fatal(err)
+	}
+	v, err := vm.Get("data.a.b[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Set("data.copy", v); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := runTestScript(t, vm, `result = [data.a.b[0].d, data.s, data.copy.c];`), `[[1,2],"y","x"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	var out struct {
+		B []interface{} `json:"b"`
+	}
+	v, err = vm.Get("data.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.B) != 2 {
+		t.Errorf("Decode: got %v, want 2 items", out.B)
+	}
+
+	for _, path := range []string{"data.s.x", "data.nothing.x", "throwing.p"} {
+		if err := vm.Set(path, 1); err == nil {
+			t.Errorf("Set(%q): got nil, want an error", path)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+const (
+	encryptedHeaderLength = 16
+)
+
+var (
+	// encryptedSignature is the beginning of the header of encrypted files.
+	encryptedSignature = []byte("RPGMV\x00\x00\x00")
+
+	encryptedExts = map[string]string{
+		".png": ".rpgmvp",
+		".ogg": ".rpgmvo",
+		".m4a": ".rpgmvm",
+	}
+
+	// encryptionIgnores is the files that are not encrypted even when the game is encrypted.
+	// This is the same as Decrypter._ignoreList in rpg_core.js.
+	encryptionIgnores = map[string]struct{}{
+		"img/system/Window.png": struct{}{},
+	}
+)
+
+type encryption struct {
+	images bool
+	audio  bool
+	key    []byte
+
+	once sync.Once
+	err  error
+}
+
+// load loads the encryption settings from System.json only once,
+// as ReadAsset can be called on multiple goroutines.
+func (e *encryption) load(fsys fs.FS) error {
+	e.once.Do(func() {
+		e.err = e.loadSystemJSON(fsys)
+	})
+	return e.err
+}
+
+func (e *encryption) loadSystemJSON(fsys fs.FS) error {
+	s := &struct {
+		HasEncryptedImages bool   `json:"hasEncryptedImages"`
+		HasEncryptedAudio  bool   `json:"hasEncryptedAudio"`
+		EncryptionKey      string `json:"encryptionKey"`
+	}{}
+	if err := readJSON(fsys, "data/System.json", s); err != nil {
+		return err
+	}
+	e.images = s.HasEncryptedImages
+	e.audio = s.HasEncryptedAudio
+	if e.images || e.audio {
+		key, err := hex.DecodeString(s.EncryptionKey)
+		if err != nil {
+			return fmt.Errorf("js: invalid encryptionKey in System.json: %v", err)
+		}
+		e.key = key
+	}
+	return nil
+}
+
+// decrypt decrypts the data of an encrypted file like .rpgmvp.
+func decrypt(data []byte, key []byte) ([]byte, error) {
+	if len(data) < encryptedHeaderLength || !bytes.HasPrefix(data, encryptedSignature) {
+		return nil, errors.New("js: invalid header of an encrypted file")
+	}
+	if len(key) == 0 {
+		return nil, errors.New("js: encryptionKey is not specified")
+	}
+	body := make([]byte, len(data)-encryptedHeaderLength)
+	copy(body, data[encryptedHeaderLength:])
+	for i := 0; i < encryptedHeaderLength && i < len(body); i++ {
+		body[i] ^= key[i%len(key)]
+	}
+	return body, nil
+}
+
+func isEncryptedFile(name string) bool {
+	ext := path.Ext(name)
+	for _, e := range encryptedExts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptedName returns the name of the encrypted file for name, like img/foo.rpgmvp for img/foo.png.
+// EncryptedName returns false if files like name are never encrypted.
+func EncryptedName(name string) (string, bool) {
+	ext := path.Ext(name)
+	encExt, ok := encryptedExts[ext]
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(name, ext) + encExt, true
+}
+
+// encryptedFile returns the name of the encrypted file for name if the game's assets are encrypted.
+func (e *encryption) encryptedFile(name string) (string, bool) {
+	if _, ok := encryptionIgnores[name]; ok {
+		return "", false
+	}
+	ext := path.Ext(name)
+	if ext == ".png" && !e.images {
+		return "", false
+	}
+	if ext != ".png" && !e.audio {
+		return "", false
+	}
+	return EncryptedName(name)
+}
+
+// ReadAsset reads a file of the game.
+//
+// If the game's images or audio are encrypted, ReadAsset reads the encrypted file instead
+// (.rpgmvp for .png, .rpgmvo for .ogg and .rpgmvm for .m4a) and returns the decrypted content.
+// Encrypted files can also be specified directly.
+func (vm *VM) ReadAsset(name string) ([]byte, error) {
+	return readAsset(vm.fs, vm.encryption, name)
+}
+
+// readAsset reads a file of the game in fsys, decrypting it with e. See ReadAsset.
+func readAsset(fsys fs.FS, e *encryption, name string) ([]byte, error) {
+	if err := e.load(fsys); err != nil {
+		return nil, err
+	}
+	name = fsPath(name)
+	if enc, ok := e.encryptedFile(name); ok {
+		b, err := fs.ReadFile(fsys, enc)
+		if err == nil {
+			return decrypt(b, e.key)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		// Fall back to the unencrypted file.
+	}
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if isEncryptedFile(name) {
+		return decrypt(b, e.key)
+	}
+	return b, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecrypt(t *testing.T) {
+	key := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	header := []byte("RPGMV\x00\x00\x00\x00\x03\x01\x00\x00\x00\x00\x00")
+
+	// Only the first 16 bytes after the header are XORed with the key.
+	plain := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x10")
+	encrypted := append([]byte{}, header...)
+	for i, b := range plain {
+		if i < len(key) {
+			b ^= key[i]
+		}
+		encrypted = append(encrypted, b)
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+		key  []byte
+		want []byte
+		err  bool
+	}{
+		{
+			name: "valid",
+			data: encrypted,
+			key:  key,
+			want: plain,
+		},
+		{
+			name: "short body",
+			data: append(append([]byte{}, header...), 0x88, 0x52),
+			key:  key,
+			want: []byte{0x89, 0x50},
+		},
+		{
+			name: "header only",
+			data: header,
+			key:  key,
+			want: []byte{},
+		},
+		{
+			name: "bad header",
+			data: append([]byte("RPGMZ\x00\x00\x00\x00\x03\x01\x00\x00\x00\x00\x00"), plain...),
+			key:  key,
+			err:  true,
+		},
+		{
+			name: "short file",
+			data: header[:10],
+			key:  key,
+			err:  true,
+		},
+		{
+			name: "empty file",
+			data: []byte{},
+			key:  key,
+			err:  true,
+		},
+		{
+			name: "no key",
+			data: encrypted,
+			err:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decrypt(tc.data, tc.key)
+			if tc.err {
+				if err == nil {
+					t.Errorf("got %x, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("got %x, want %x", got, tc.want)
+			}
+		})
+	}
+}
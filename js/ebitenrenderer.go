@@ -0,0 +1,166 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+const (
+	emptyImageSize = 16
+)
+
+type ebitenRenderer struct {
+	emptyImage *ebiten.Image
+}
+
+// NewEbitenRenderer returns a Renderer drawing with Ebiten, which uses the GPU.
+func NewEbitenRenderer() (Renderer, error) {
+	emptyImage, err := ebiten.NewImage(emptyImageSize, emptyImageSize, ebiten.FilterNearest)
+	if err != nil {
+		return nil, err
+	}
+	if err := emptyImage.Fill(color.White); err != nil {
+		return nil, err
+	}
+	return &ebitenRenderer{
+		emptyImage: emptyImage,
+	}, nil
+}
+
+func (r *ebitenRenderer) NewImage(width, height int) (Image, error) {
+	img, err := ebiten.NewImage(width, height, ebiten.FilterNearest)
+	if err != nil {
+		return nil, err
+	}
+	return &ebitenImage{r, img}, nil
+}
+
+func (r *ebitenRenderer) NewImageFromImage(source image.Image) (Image, error) {
+	img, err := ebiten.NewImageFromImage(source, ebiten.FilterNearest)
+	if err != nil {
+		return nil, err
+	}
+	return &ebitenImage{r, img}, nil
+}
+
+type ebitenImage struct {
+	renderer *ebitenRenderer
+	img      *ebiten.Image
+}
+
+func (i *ebitenImage) Size() (int, int) {
+	return i.img.Size()
+}
+
+func (i *ebitenImage) Clear() error {
+	return i.img.Clear()
+}
+
+func (i *ebitenImage) ClearRect(x, y, width, height int) error {
+	w, h := i.img.Size()
+	if x == 0 && y == 0 && width == w && height == h {
+		return i.img.Clear()
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(width)/emptyImageSize, float64(height)/emptyImageSize)
+	op.GeoM.Translate(float64(x), float64(y))
+	op.CompositeMode = ebiten.CompositeModeClear
+	return i.img.DrawImage(i.renderer.emptyImage, op)
+}
+
+func (i *ebitenImage) FillRect(x, y, width, height int, clr color.NRGBA) error {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(width)/emptyImageSize, float64(height)/emptyImageSize)
+	op.GeoM.Translate(float64(x), float64(y))
+	rf := float64(clr.R) / 0xff
+	gf := float64(clr.G) / 0xff
+	bf := float64(clr.B) / 0xff
+	af := float64(clr.A) / 0xff
+	op.ColorM.Scale(rf, gf, bf, af)
+	return i.img.DrawImage(i.renderer.emptyImage, op)
+}
+
+type ebitenImageParts []ImagePart
+
+func (p ebitenImageParts) Len() int {
+	return len(p)
+}
+
+func (p ebitenImageParts) Src(i int) (int, int, int, int) {
+	part := p[i]
+	return part.SrcX0, part.SrcY0, part.SrcX1, part.SrcY1
+}
+
+func (p ebitenImageParts) Dst(i int) (int, int, int, int) {
+	part := p[i]
+	return part.DstX0, part.DstY0, part.DstX1, part.DstY1
+}
+
+var (
+	ebitenCompositeModes = map[CompositeMode]ebiten.CompositeMode{
+		CompositeModeSourceOver:      ebiten.CompositeModeSourceOver,
+		CompositeModeClear:           ebiten.CompositeModeClear,
+		CompositeModeCopy:            ebiten.CompositeModeCopy,
+		CompositeModeDestinationOver: ebiten.CompositeModeDestinationOver,
+		CompositeModeSourceIn:        ebiten.CompositeModeSourceIn,
+		CompositeModeDestinationIn:   ebiten.CompositeModeDestinationIn,
+		CompositeModeSourceOut:       ebiten.CompositeModeSourceOut,
+		CompositeModeDestinationOut:  ebiten.CompositeModeDestinationOut,
+		CompositeModeSourceAtop:      ebiten.CompositeModeSourceAtop,
+		CompositeModeDestinationAtop: ebiten.CompositeModeDestinationAtop,
+		CompositeModeXor:             ebiten.CompositeModeXor,
+		CompositeModeLighter:         ebiten.CompositeModeLighter,
+	}
+)
+
+func (i *ebitenImage) DrawImage(src Image, op *DrawImageOptions) error {
+	s, ok := src.(*ebitenImage)
+	if !ok {
+		return fmt.Errorf("js: the source image is not an Ebiten image: %T", src)
+	}
+	g := op.geoM()
+	eop := &ebiten.DrawImageOptions{}
+	eop.ImageParts = ebitenImageParts(op.imageParts(src))
+	eop.GeoM.SetElement(0, 0, g[0])
+	eop.GeoM.SetElement(1, 0, g[1])
+	eop.GeoM.SetElement(0, 1, g[2])
+	eop.GeoM.SetElement(1, 1, g[3])
+	eop.GeoM.SetElement(0, 2, g[4])
+	eop.GeoM.SetElement(1, 2, g[5])
+	eop.ColorM.Scale(1, 1, 1, op.Alpha)
+	eop.CompositeMode = ebitenCompositeModes[op.CompositeMode]
+	return i.img.DrawImage(s.img, eop)
+}
+
+func (i *ebitenImage) ReplacePixels(pix []uint8) error {
+	return i.img.ReplacePixels(pix)
+}
+
+func (i *ebitenImage) ColorModel() color.Model {
+	return i.img.ColorModel()
+}
+
+func (i *ebitenImage) Bounds() image.Rectangle {
+	return i.img.Bounds()
+}
+
+func (i *ebitenImage) At(x, y int) color.Color {
+	return i.img.At(x, y)
+}
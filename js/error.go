@@ -0,0 +1,302 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/gophermv/transpile"
+	"gopkg.in/olebedev/go-duktape.v2"
+)
+
+const sourceContextLines = 2
+
+// Error is an error thrown in JavaScript.
+type Error struct {
+	// Type is the name of the error like TypeError.
+	Type    string
+	Message string
+
+	// FileName is the script where the error was thrown.
+	FileName string
+
+	// Line is the 1-based line number. Line is 0 if unknown.
+	Line int
+
+	// Column is the 1-based column number in characters. Column is 0 if unknown.
+	// Duktape reports only lines, so Column is known only for syntax errors,
+	// which are found again by parsing the script.
+	Column int
+
+	// Source is the lines of the script around Line.
+	Source []SourceLine
+
+	// Stack is the stack trace in JavaScript.
+	Stack string
+
+	// Cause is the error returned by the Go function that threw this error, if any.
+	Cause error
+}
+
+// SourceLine is a line of a script.
+type SourceLine struct {
+	Line int
+	Text string
+}
+
+// Error returns a report of the error including the source lines and the stack.
+func (e *Error) Error() string {
+	lines := []string{}
+	if e.Type != "" {
+		lines = append(lines, e.Type+": "+e.Message)
+	} else {
+		lines = append(lines, e.Message)
+	}
+	if e.FileName != "" {
+		loc := e.FileName
+		if 0 < e.Line {
+			loc += ":" + strconv.Itoa(e.Line)
+			if 0 < e.Column {
+				loc += ":" + strconv.Itoa(e.Column)
+			}
+		}
+		lines = append(lines, "    at "+loc)
+	}
+	if 0 < len(e.Source) {
+		lines = append(lines, "")
+		width := len(strconv.Itoa(e.Source[len(e.Source)-1].Line))
+		for _, l := range e.Source {
+			mark := "  "
+			if l.Line == e.Line {
+				mark = "> "
+			}
+			lines = append(lines, fmt.Sprintf("  %s%*d | %s", mark, width, l.Line, l.Text))
+			if l.Line == e.Line && 0 < e.Column {
+				lines = append(lines, fmt.Sprintf("    %*s | %s^", width, "", caretIndent(l.Text, e.Column)))
+			}
+		}
+	}
+	if e.Stack != "" {
+		// The first line of the stack is the same as the message.
+		stack := strings.Split(e.Stack, "\n")
+		if 1 < len(stack) {
+			lines = append(lines, "")
+			lines = append(lines, stack[1:]...)
+		}
+	}
+	if e.Cause != nil {
+		lines = append(lines, "", "caused by: "+e.Cause.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the Go error that caused the error.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// caretIndent returns the spaces to put a caret under the column of text.
+// Tabs are kept so that the caret is aligned.
+func caretIndent(text string, column int) string {
+	var b strings.Builder
+	for i, r := range []rune(text) {
+		if column-1 <= i {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+			continue
+		}
+		b.WriteRune(' ')
+	}
+	return b.String()
+}
+
+// source returns the script of filename.
+func (vm *VM) source(filename string) (string, bool) {
+	if filename == "" {
+		return "", false
+	}
+	if s, ok := vm.sources[filename]; ok {
+		return s, true
+	}
+	b, err := fs.ReadFile(vm.fs, fsPath(filename))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// syntaxErrorColumn returns the column of the syntax error at line of the script, or 0 if unknown.
+func (vm *VM) syntaxErrorColumn(filename string, line int) int {
+	s, ok := vm.source(filename)
+	if !ok {
+		return 0
+	}
+	l, c, ok := transpile.SyntaxErrorPosition([]byte(s))
+	// The parser might find another error than Duktape's, or none as it accepts ES2015 and later.
+	if !ok || l != line {
+		return 0
+	}
+	return c
+}
+
+// sourceLines returns the lines around line of the script.
+func (vm *VM) sourceLines(filename string, line int) []SourceLine {
+	if line <= 0 {
+		return nil
+	}
+	s, ok := vm.source(filename)
+	if !ok {
+		return nil
+	}
+	src := strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	if len(src) < line {
+		return nil
+	}
+	ls := []SourceLine{}
+	for i := line - sourceContextLines; i <= line+sourceContextLines; i++ {
+		if i < 1 || len(src) < i {
+			continue
+		}
+		ls = append(ls, SourceLine{
+			Line: i,
+			Text: src[i-1],
+		})
+	}
+	return ls
+}
+
+// intToError converts the error at the stack top into an Error if result is not 0.
+func (vm *VM) intToError(result int) error {
+	if result == 0 {
+		return nil
+	}
+	return vm.stackError()
+}
+
+// stackError converts the value thrown in JavaScript at the stack top into an Error.
+// The properties like lineNumber are read from the error object.
+func (vm *VM) stackError() *Error {
+	if !vm.context.IsObject(-1) {
+		return &Error{
+			Message: vm.context.SafeToString(-1),
+		}
+	}
+	err := &Error{}
+	for _, key := range []string{"name", "message", "fileName", "lineNumber", "stack", "_gophermv_goErrorID"} {
+		vm.context.GetPropString(-1, key)
+		switch key {
+		case "name":
+			err.Type = vm.context.SafeToString(-1)
+		case "message":
+			err.Message = vm.context.SafeToString(-1)
+		case "fileName":
+			err.FileName = vm.context.SafeToString(-1)
+		case "lineNumber":
+			if vm.context.IsNumber(-1) {
+				err.Line = vm.context.GetInt(-1)
+			}
+		case "stack":
+			err.Stack = vm.context.SafeToString(-1)
+		case "_gophermv_goErrorID":
+			if vm.context.IsNumber(-1) && vm.goError != nil && vm.goError.id == vm.context.GetInt(-1) {
+				err.Cause = vm.goError.err
+			}
+		}
+		vm.context.Pop()
+	}
+	if err.Type == "SyntaxError" {
+		err.Column = vm.syntaxErrorColumn(err.FileName, err.Line)
+	}
+	err.Source = vm.sourceLines(err.FileName, err.Line)
+	return err
+}
+
+// toError converts a *duktape.Error, which is returned by e.g. PevalString, into an Error.
+func (vm *VM) toError(err error) error {
+	derr, ok := err.(*duktape.Error)
+	if !ok {
+		return err
+	}
+	e := &Error{
+		Type:     derr.Type,
+		Message:  derr.Message,
+		FileName: derr.FileName,
+		Line:     derr.LineNumber,
+		Source:   vm.sourceLines(derr.FileName, derr.LineNumber),
+		Stack:    derr.Stack,
+	}
+	if e.Type == "SyntaxError" {
+		e.Column = vm.syntaxErrorColumn(e.FileName, e.Line)
+	}
+	return e
+}
+
+// goError is an error returned by a Go function called from JavaScript.
+type goError struct {
+	id    int
+	err   error
+	taken bool
+}
+
+func jsTakeGoError(vm *VM) (int, error) {
+	if vm.goError == nil || vm.goError.taken {
+		vm.context.PushNull()
+		return 1, nil
+	}
+	vm.goError.taken = true
+	vm.context.PushArray()
+	vm.context.PushInt(vm.goError.id)
+	vm.context.PutPropIndex(-2, 0)
+	vm.context.PushString(vm.goError.err.Error())
+	vm.context.PutPropIndex(-2, 1)
+	return 1, nil
+}
+
+// errorSrc gives the message of the Go error to the error thrown by a Go function.
+// As Duktape creates the error as soon as the Go function returns,
+// the Go error not taken yet belongs to the error being created.
+const errorSrc = `
+(function() {
+  Duktape.errCreate = function(e) {
+    if (!(e instanceof Error)) {
+      return e;
+    }
+    var goError = _gophermv_takeGoError();
+    if (goError === null) {
+      return e;
+    }
+    e.message = goError[1];
+    e._gophermv_goErrorID = goError[0];
+    return e;
+  };
+})();
+`
+
+func (vm *VM) initError() error {
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_takeGoError", wrapFunc(jsTakeGoError, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if err := vm.context.PevalString(errorSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestErrorReport(t *testing.T) {
+	const src = `var a = 1;
+function f() {
+  throw new TypeError('boom');
+}
+f();
+`
+	vm := newTestVM(t, fstest.MapFS{
+		"js/error.js": {Data: []byte(src)},
+	})
+	err := vm.exec("js/error.js")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v (%T), want *Error", err, err)
+	}
+	if e.Type != "TypeError" || e.Message != "boom" {
+		t.Errorf("got %q, %q, want %q, %q", e.Type, e.Message, "TypeError", "boom")
+	}
+	if e.FileName != "js/error.js" || e.Line != 3 {
+		t.Errorf("got %s:%d, want js/error.js:3", e.FileName, e.Line)
+	}
+	stack := strings.Split(e.Stack, "\n")
+	if len(stack) < 2 {
+		t.Fatalf("the stack has no frames: %q", e.Stack)
+	}
+
+	report := e.Error()
+	for _, want := range []string{
+		"TypeError: boom\n    at js/error.js:3\n",
+		"    1 | var a = 1;\n",
+		"  > 3 |   throw new TypeError('boom');\n",
+		"    5 | f();\n",
+		"\n" + strings.Join(stack[1:], "\n"),
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the report doesn't include %q:\n%s", want, report)
+		}
+	}
+	if !strings.HasPrefix(report, "TypeError: boom\n") {
+		t.Errorf("the report doesn't start with the message:\n%s", report)
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+	vm := newTestVM(t, nil)
+	if err := vm.Register("fail", func() error {
+		return fmt.Errorf("failed: %w", errSentinel)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "direct",
+			src:  `fail();`,
+		},
+		{
+			name: "nested",
+			src:  `function f() { fail(); } f();`,
+		},
+		{
+			name: "rethrown",
+			src:  `try { fail(); } catch (e) { throw e; }`,
+		},
+	}
+	for _, tc := range testCases {
+		err := vm.execSource("test.js", tc.src)
+		if !errors.Is(err, errSentinel) {
+			t.Errorf("%s: errors.Is(%v, errSentinel) must be true", tc.name, err)
+		}
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: got %T, want *Error", tc.name, err)
+			continue
+		}
+		if e.Message != "failed: sentinel" {
+			t.Errorf("%s: got %q, want %q", tc.name, e.Message, "failed: sentinel")
+		}
+		if !strings.Contains(e.Error(), "caused by: failed: sentinel") {
+			t.Errorf("%s: the report doesn't include the cause:\n%s", tc.name, e.Error())
+		}
+	}
+}
+
+func TestErrorSyntaxColumn(t *testing.T) {
+	vm := newTestVM(t, fstest.MapFS{
+		"js/syntax.js": {Data: []byte("var a = 1;\nvar b = (1 +;\n")},
+		"js/json.js":   {Data: []byte("JSON.parse('{');\n")},
+	})
+
+	err := vm.exec("js/syntax.js")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v (%T), want *Error", err, err)
+	}
+	if e.Type != "SyntaxError" || e.Line != 2 || e.Column != 13 {
+		t.Errorf("got %s at %d:%d, want SyntaxError at 2:13", e.Type, e.Line, e.Column)
+	}
+	report := e.Error()
+	for _, want := range []string{
+		"    at js/syntax.js:2:13\n",
+		"  > 2 | var b = (1 +;\n" +
+			"      |             ^\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the report doesn't include %q:\n%s", want, report)
+		}
+	}
+
+	// A syntax error thrown at runtime has no column.
+	err = vm.exec("js/json.js")
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v (%T), want *Error", err, err)
+	}
+	if e.Type != "SyntaxError" || e.Column != 0 {
+		t.Errorf("got %s with column %d, want SyntaxError with column 0", e.Type, e.Column)
+	}
+}
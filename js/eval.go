@@ -0,0 +1,98 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+type evalResult struct {
+	json string
+	err  error
+}
+
+type evalRequest struct {
+	src      string
+	resultCh chan evalResult
+}
+
+// Eval evaluates the JavaScript src in the global scope and returns the result as JSON.
+// If the result is undefined, Eval returns "undefined".
+//
+// Eval can be called from any goroutine while the game is running.
+// src is evaluated on the VM's goroutine between frames.
+func (vm *VM) Eval(src string) (string, error) {
+	r := &evalRequest{
+		src:      src,
+		resultCh: make(chan evalResult, 1),
+	}
+	select {
+	case vm.evalCh <- r:
+	case <-vm.terminatedCh:
+		return "", errTerminated
+	}
+	res := <-r.resultCh
+	return res.json, res.err
+}
+
+func (vm *VM) eval(src string) (string, error) {
+	vm.context.GetGlobalString("_gophermv_eval")
+	vm.context.PushString(src)
+	if err := vm.intToError(vm.context.Pcall(1)); err != nil {
+		vm.context.Pop()
+		return "", err
+	}
+	json := vm.context.SafeToString(-1)
+	vm.context.Pop()
+	if err := vm.processMicrotasks(); err != nil {
+		return "", err
+	}
+	return json, nil
+}
+
+const evalSrc = `
+function _gophermv_eval(src) {
+  var result = (0, eval)(src);
+  if (result === undefined) {
+    return 'undefined';
+  }
+  // ancestors is the objects from the root to the holder of the current value.
+  // An object shared by siblings is not circular.
+  var ancestors = [];
+  var json = JSON.stringify(result, function(key, value) {
+    if (typeof value === 'function') {
+      return '[Function]';
+    }
+    while (ancestors.length && ancestors[ancestors.length - 1] !== this) {
+      ancestors.pop();
+    }
+    if (typeof value === 'object' && value !== null) {
+      if (ancestors.indexOf(value) !== -1) {
+        return '[Circular]';
+      }
+      ancestors.push(value);
+    }
+    return value;
+  });
+  if (json === undefined) {
+    return String(result);
+  }
+  return json;
+}
+`
+
+func (vm *VM) initEval() error {
+	if err := vm.context.PevalString(evalSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
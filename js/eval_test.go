@@ -0,0 +1,100 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEvalDuringFrames(t *testing.T) {
+	src := testScreenSrc + `
+var frames = 0;
+requestAnimationFrame(function update() {
+  frames++;
+  requestAnimationFrame(update);
+});
+var obj = {a: [1, 'x'], f: function() {}};
+obj.self = obj;
+var resolved = 0;
+`
+	vm := newTestVM(t, fstest.MapFS{
+		"js/test.js": {Data: []byte(src)},
+	})
+	vm.Enqueue("js/test.js")
+
+	type evalTest struct {
+		src     string
+		want    string
+		errType string
+	}
+	tests := []evalTest{
+		{src: "obj", want: `{"a":[1,"x"],"f":"[Function]","self":"[Circular]"}`},
+		{src: "var x = 1", want: "undefined"},
+		{src: "x + 1", want: "2"},
+		{src: "throw new TypeError('bad')", errType: "TypeError"},
+		{src: "(", errType: "SyntaxError"},
+		// The microtasks run before Eval returns.
+		{src: "Promise.resolve(3).then(function(v) { resolved = v; }); resolved", want: "0"},
+		{src: "resolved", want: "3"},
+	}
+
+	stop := errors.New("stop")
+	done := make(chan error, 1)
+	go func() {
+		done <- func() error {
+			for _, test := range tests {
+				got, err := vm.Eval(test.src)
+				if test.errType != "" {
+					var jsErr *Error
+					if !errors.As(err, &jsErr) || jsErr.Type != test.errType {
+						return fmt.Errorf("Eval(%q): got error %v, want %s", test.src, err, test.errType)
+					}
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("Eval(%q): %v", test.src, err)
+				}
+				if got != test.want {
+					return fmt.Errorf("Eval(%q): got %s, want %s", test.src, got, test.want)
+				}
+			}
+			return nil
+		}()
+	}()
+
+	// The game runs until all the evaluations finish.
+	var evalErr error
+	vm.SetFrameHandler(func(frame int, screen Image) error {
+		select {
+		case evalErr = <-done:
+			return stop
+		default:
+			return nil
+		}
+	})
+	if err := vm.RunHeadless(1 << 30); err != stop {
+		t.Fatalf("RunHeadless: got %v, want %v", err, stop)
+	}
+	if evalErr != nil {
+		t.Error(evalErr)
+	}
+
+	if _, err := vm.Eval("1"); err == nil {
+		t.Error("Eval after the game ends: got nil, want an error")
+	}
+}
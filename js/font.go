@@ -18,12 +18,10 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"io/ioutil"
+	"io/fs"
 	"math"
-	"path/filepath"
 
 	"github.com/golang/freetype/truetype"
-	"github.com/hajimehoshi/ebiten"
 	gofont "golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
 )
@@ -31,11 +29,11 @@ import (
 type font struct {
 	tt       *truetype.Font
 	textImg  *image.RGBA
-	textEImg *ebiten.Image
+	textEImg Image
 }
 
-func newFont(path string) (*font, error) {
-	b, err := ioutil.ReadFile(filepath.Join(path, "fonts", "mplus-1m-regular.ttf"))
+func newFont(fsys fs.FS) (*font, error) {
+	b, err := fs.ReadFile(fsys, "fonts/mplus-1m-regular.ttf")
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +144,7 @@ const (
 	fontDPI = 72
 )
 
-func (f *font) drawText(img *ebiten.Image, text string, size, lineWidth int, x, y int, maxWidth int, align align, clr color.Color) error {
+func (f *font) drawText(renderer Renderer, img Image, text string, size, lineWidth int, x, y int, maxWidth int, align align, clr color.Color) error {
 	const imgWidth = 800
 	const imgHeight = 600
 	if f.textImg == nil {
@@ -177,8 +175,10 @@ func (f *font) drawText(img *ebiten.Image, text string, size, lineWidth int, x,
 		pix = makePixelsFat(f.textImg.Pix, imgWidth, imgHeight, f.textImg.Stride, lineWidth / 2)
 	}
 	if f.textEImg == nil {
+		// The text image is drawn at the same scale and at an integer position,
+		// so the result is the same regardless of the filter.
 		var err error
-		f.textEImg, err = ebiten.NewImage(imgWidth, imgHeight, ebiten.FilterLinear)
+		f.textEImg, err = renderer.NewImage(imgWidth, imgHeight)
 		if err != nil {
 			return err
 		}
@@ -187,7 +187,9 @@ func (f *font) drawText(img *ebiten.Image, text string, size, lineWidth int, x,
 	if err := f.textEImg.ReplacePixels(pix); err != nil {
 		return err
 	}
-	op := &ebiten.DrawImageOptions{}
+	op := &DrawImageOptions{
+		Alpha: 1,
+	}
 	if err := img.DrawImage(f.textEImg, op); err != nil {
 		return err
 	}
@@ -0,0 +1,61 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"io"
+	"io/fs"
+
+	"golang.org/x/net/html"
+)
+
+// ParseScripts parses an HTML document and returns the src attributes of its script elements in order.
+func ParseScripts(r io.Reader) ([]string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	scriptNodes := []*html.Node{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" {
+			scriptNodes = append(scriptNodes, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	scripts := []string{}
+	for _, n := range scriptNodes {
+		for _, a := range n.Attr {
+			if a.Key != "src" {
+				continue
+			}
+			scripts = append(scripts, a.Val)
+		}
+	}
+	return scripts, nil
+}
+
+// LoadScripts returns the scripts in index.html at the root of fsys.
+func LoadScripts(fsys fs.FS) ([]string, error) {
+	f, err := fsys.Open(indexHTMLFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScripts(f)
+}
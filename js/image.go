@@ -15,6 +15,7 @@
 package js
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"image"
@@ -22,50 +23,45 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/hajimehoshi/ebiten"
 	"gopkg.in/olebedev/go-duktape.v2"
 )
 
-var (
-	// imagesInJS holds images not to be collected by GC.
-	imagesInJS = map[int]*ebiten.Image{}
-)
-
 func (vm *VM) newImageID() int {
 	vm.lastImageID++
 	return vm.lastImageID
 }
 
-func (vm *VM) pushEbitenImage(img *ebiten.Image) {
+func (vm *VM) pushEbitenImage(img Image) {
 	vm.context.PushObject()
 	id := vm.newImageID()
 	vm.context.PushInt(id)
 	vm.context.PutPropString(-2, "id")
-	imagesInJS[id] = img
+	vm.images[id] = img
 	vm.context.PushGoFunction(wrapFunc(func(vm *VM) (int, error) {
-		delete(imagesInJS, id)
+		delete(vm.images, id)
 		return 0, nil
 	}, vm))
 	vm.context.SetFinalizer(-2)
 }
 
-func (vm *VM) getEbitenImage(index int) *ebiten.Image {
+func (vm *VM) getEbitenImage(index int) Image {
 	vm.context.GetPropString(index, "id")
 	id := vm.context.GetInt(-1)
 	vm.context.Pop()
-	return imagesInJS[id]
+	return vm.images[id]
 }
 
 func jsNewEbitenImage(vm *VM) (int, error) {
 	width := vm.context.GetInt(0)
 	height := vm.context.GetInt(1)
-	img, err := ebiten.NewImage(int(width), int(height), ebiten.FilterNearest)
+	img, err := vm.renderer.NewImage(width, height)
 	if err != nil {
 		return 0, err
 	}
@@ -77,28 +73,151 @@ var (
 	pngDataURLRe = regexp.MustCompile(`^data:image/png;base64,(.+)$`)
 )
 
-func jsLoadEbitenImage(vm *VM) (int, error) {
-	src := vm.context.GetString(0)
+// imageRequest is a request to load an image. The image is decoded by one of the goroutines of imageDecoder.
+type imageRequest struct {
+	id   int
+	src  string
+	done chan struct{}
+	img  image.Image
+	err  error
+}
+
+// imageDecoder decodes the images of imageRequests on a bounded number of goroutines.
+//
+// The goroutines don't refer to the VM so that the VM can be garbage-collected.
+// The goroutines exit when close is called.
+type imageDecoder struct {
+	fsys       fs.FS
+	encryption *encryption
+	requests   []*imageRequest
+	closed     bool
+	m          sync.Mutex
+	cond       *sync.Cond
+}
+
+func newImageDecoder(fsys fs.FS, encryption *encryption) *imageDecoder {
+	d := &imageDecoder{
+		fsys:       fsys,
+		encryption: encryption,
+	}
+	d.cond = sync.NewCond(&d.m)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go d.run()
+	}
+	return d
+}
+
+// enqueue adds r to the requests to decode. enqueue doesn't block even when all the goroutines are busy.
+func (d *imageDecoder) enqueue(r *imageRequest) {
+	d.m.Lock()
+	d.requests = append(d.requests, r)
+	d.m.Unlock()
+	d.cond.Signal()
+}
+
+// next waits for a request and returns it. next returns false when d is closed.
+func (d *imageDecoder) next() (*imageRequest, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for len(d.requests) == 0 && !d.closed {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return nil, false
+	}
+	r := d.requests[0]
+	d.requests[0] = nil
+	d.requests = d.requests[1:]
+	return r, true
+}
+
+func (d *imageDecoder) run() {
+	for {
+		r, ok := d.next()
+		if !ok {
+			return
+		}
+		r.img, r.err = decodeImage(d.fsys, d.encryption, r.src)
+		close(r.done)
+	}
+}
+
+// close stops the goroutines. The requests not decoded yet are never completed.
+func (d *imageDecoder) close() {
+	d.m.Lock()
+	d.closed = true
+	d.requests = nil
+	d.m.Unlock()
+	d.cond.Broadcast()
+}
+
+func decodeImage(fsys fs.FS, encryption *encryption, src string) (image.Image, error) {
 	var in io.Reader
 	if m := pngDataURLRe.FindStringSubmatch(src); m != nil {
 		in = base64.NewDecoder(base64.StdEncoding, strings.NewReader(m[1]))
 	} else {
-		f, err := os.Open(filepath.Join(vm.pwd, src))
+		b, err := readAsset(fsys, encryption, src)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		defer f.Close()
-		in = f
+		in = bytes.NewReader(b)
 	}
 	img, _, err := image.Decode(in)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("js: decoding %s failed: %v", src, err)
+	}
+	return img, nil
+}
+
+// jsRequestImage starts loading an image and returns the request ID.
+func jsRequestImage(vm *VM) (int, error) {
+	if vm.imageDecoder == nil {
+		vm.imageDecoder = newImageDecoder(vm.fs, vm.encryption)
+	}
+	vm.lastImageReqID++
+	r := &imageRequest{
+		id:   vm.lastImageReqID,
+		src:  vm.context.GetString(0),
+		done: make(chan struct{}),
+	}
+	vm.imageRequests = append(vm.imageRequests, r)
+	vm.imageDecoder.enqueue(r)
+	vm.context.PushInt(r.id)
+	return 1, nil
+}
+
+// jsWaitImage waits for the earliest image request and returns [id, image].
+// The image is null if loading failed, and the error is reported as a warning.
+// If there is no request, jsWaitImage returns null.
+//
+// As the requests are completed in order, the game runs deterministically
+// even though images are decoded in parallel.
+func jsWaitImage(vm *VM) (int, error) {
+	if len(vm.imageRequests) == 0 {
+		vm.context.PushNull()
+		return 1, nil
+	}
+	r := vm.imageRequests[0]
+	vm.imageRequests = vm.imageRequests[1:]
+	<-r.done
+	if r.err != nil {
+		vm.warnf("Image: %v\n", r.err)
+		vm.context.PushArray()
+		vm.context.PushInt(r.id)
+		vm.context.PutPropIndex(-2, 0)
+		vm.context.PushNull()
+		vm.context.PutPropIndex(-2, 1)
+		return 1, nil
 	}
-	eimg, err := ebiten.NewImageFromImage(img, ebiten.FilterNearest)
+	img, err := vm.renderer.NewImageFromImage(r.img)
 	if err != nil {
 		return 0, err
 	}
-	vm.pushEbitenImage(eimg)
+	vm.context.PushArray()
+	vm.context.PushInt(r.id)
+	vm.context.PutPropIndex(-2, 0)
+	vm.pushEbitenImage(img)
+	vm.context.PutPropIndex(-2, 1)
 	return 1, nil
 }
 
@@ -113,73 +232,22 @@ func jsEbitenImageSize(vm *VM) (int, error) {
 	return 1, nil
 }
 
-const (
-	emptyImageSize = 16
-)
-
-var (
-	emptyImage *ebiten.Image
-)
-
-func init() {
-	var err error
-	emptyImage, err = ebiten.NewImage(emptyImageSize, emptyImageSize, ebiten.FilterNearest)
-	if err != nil {
-		panic(err)
-	}
-	if err := emptyImage.Fill(color.White); err != nil {
-		panic(err)
-	}
-}
-
 func jsEbitenImageClearRect(vm *VM) (int, error) {
 	img := vm.getEbitenImage(0)
 	x := vm.context.GetInt(1)
 	y := vm.context.GetInt(2)
 	width := vm.context.GetInt(3)
 	height := vm.context.GetInt(4)
-	w, h := img.Size()
-	if x == 0 && y == 0 && int(width) == w && int(height) == h {
-		if err := img.Clear(); err != nil {
-			return 0, err
-		}
-		return 0, nil
-	}
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(float64(width)/emptyImageSize, float64(height)/emptyImageSize)
-	op.GeoM.Translate(float64(x), float64(y))
-	op.CompositeMode = ebiten.CompositeModeClear
-	if err := img.DrawImage(emptyImage, op); err != nil {
+	if err := img.ClearRect(x, y, width, height); err != nil {
 		return 0, err
 	}
 	return 0, nil
 }
 
-type imagePart struct {
-	sx0, sy0, sx1, sy1 int
-	dx0, dy0, dx1, dy1 int
-}
-
-type imageParts []*imagePart
-
-func (p imageParts) Len() int {
-	return len(p)
-}
-
-func (p imageParts) Src(i int) (int, int, int, int) {
-	part := p[i]
-	return part.sx0, part.sy0, part.sx1, part.sy1
-}
-
-func (p imageParts) Dst(i int) (int, int, int, int) {
-	part := p[i]
-	return part.dx0, part.dy0, part.dx1, part.dy1
-}
-
-func (vm *VM) getEbitenDrawImageOptions(index int) (*ebiten.DrawImageOptions, error) {
+func (vm *VM) getDrawImageOptions(index int) (*DrawImageOptions, error) {
 	vm.context.GetPropString(index, "imageParts")
 	n := vm.context.GetLength(-1)
-	parts := make([]*imagePart, n)
+	parts := make([]ImagePart, n)
 	for i := 0; i < n; i++ {
 		vm.context.GetPropIndex(-1, uint(i))
 		src := make([]int, 4)
@@ -198,17 +266,16 @@ func (vm *VM) getEbitenDrawImageOptions(index int) (*ebiten.DrawImageOptions, er
 			vm.context.Pop()
 		}
 		vm.context.Pop()
-		p := &imagePart{
-			sx0: src[0],
-			sy0: src[1],
-			sx1: src[2],
-			sy1: src[3],
-			dx0: dst[0],
-			dy0: dst[1],
-			dx1: dst[2],
-			dy1: dst[3],
+		parts[i] = ImagePart{
+			SrcX0: src[0],
+			SrcY0: src[1],
+			SrcX1: src[2],
+			SrcY1: src[3],
+			DstX0: dst[0],
+			DstY0: dst[1],
+			DstX1: dst[2],
+			DstY1: dst[3],
 		}
-		parts[i] = p
 		vm.context.Pop()
 	}
 	vm.context.Pop()
@@ -225,34 +292,34 @@ func (vm *VM) getEbitenDrawImageOptions(index int) (*ebiten.DrawImageOptions, er
 
 	vm.context.GetPropString(index, "compositeMode")
 	compositeModeStr := vm.context.GetString(-1)
-	compositeMode := ebiten.CompositeModeSourceOver
+	compositeMode := CompositeModeSourceOver
 	switch compositeModeStr {
 	case "source-atop":
-		compositeMode = ebiten.CompositeModeSourceAtop
+		compositeMode = CompositeModeSourceAtop
 	case "source-in":
-		compositeMode = ebiten.CompositeModeSourceIn
+		compositeMode = CompositeModeSourceIn
 	case "source-out":
-		compositeMode = ebiten.CompositeModeSourceOut
+		compositeMode = CompositeModeSourceOut
 	case "source-over":
-		compositeMode = ebiten.CompositeModeSourceOver
+		compositeMode = CompositeModeSourceOver
 	case "destination-atop":
-		compositeMode = ebiten.CompositeModeDestinationAtop
+		compositeMode = CompositeModeDestinationAtop
 	case "destination-in":
-		compositeMode = ebiten.CompositeModeDestinationIn
+		compositeMode = CompositeModeDestinationIn
 	case "destination-out":
-		compositeMode = ebiten.CompositeModeDestinationOut
+		compositeMode = CompositeModeDestinationOut
 	case "destination-over":
-		compositeMode = ebiten.CompositeModeDestinationOver
+		compositeMode = CompositeModeDestinationOver
 	case "lighter":
-		compositeMode = ebiten.CompositeModeLighter
+		compositeMode = CompositeModeLighter
 	case "clear":
-		compositeMode = ebiten.CompositeModeClear
+		compositeMode = CompositeModeClear
 	case "copy":
-		compositeMode = ebiten.CompositeModeCopy
+		compositeMode = CompositeModeCopy
 	case "xor":
-		compositeMode = ebiten.CompositeModeXor
+		compositeMode = CompositeModeXor
 	case "multiply":
-		fmt.Fprintf(os.Stderr, "composite mode 'multiply' is not supported yet.\n")
+		vm.warnf("composite mode 'multiply' is not supported yet.\n")
 	default:
 		return nil, fmt.Errorf("not supported composite mode: %s", compositeModeStr)
 	}
@@ -262,16 +329,12 @@ func (vm *VM) getEbitenDrawImageOptions(index int) (*ebiten.DrawImageOptions, er
 	alpha := vm.context.GetNumber(-1)
 	vm.context.Pop()
 
-	op := &ebiten.DrawImageOptions{}
-	op.ImageParts = imageParts(parts)
-	op.GeoM.SetElement(0, 0, geomVals[0])
-	op.GeoM.SetElement(1, 0, geomVals[1])
-	op.GeoM.SetElement(0, 1, geomVals[2])
-	op.GeoM.SetElement(1, 1, geomVals[3])
-	op.GeoM.SetElement(0, 2, geomVals[4])
-	op.GeoM.SetElement(1, 2, geomVals[5])
-	op.ColorM.Scale(1, 1, 1, alpha)
-	op.CompositeMode = compositeMode
+	op := &DrawImageOptions{
+		ImageParts:    parts,
+		GeoM:          geomVals,
+		Alpha:         alpha,
+		CompositeMode: compositeMode,
+	}
 	return op, nil
 }
 
@@ -291,15 +354,7 @@ func jsEbitenImageFillRect(vm *VM) (int, error) {
 	height := vm.context.GetInt(4)
 	clr := vm.context.GetInt(5)
 	r, g, b, a := intColorToNRGBA(clr)
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(float64(width)/emptyImageSize, float64(height)/emptyImageSize)
-	op.GeoM.Translate(float64(x), float64(y))
-	rf := float64(r) / 0xff
-	gf := float64(g) / 0xff
-	bf := float64(b) / 0xff
-	af := float64(a) / 0xff
-	op.ColorM.Scale(rf, gf, bf, af)
-	if err := img.DrawImage(emptyImage, op); err != nil {
+	if err := img.FillRect(x, y, width, height, color.NRGBA{r, g, b, a}); err != nil {
 		return 0, err
 	}
 	return 0, nil
@@ -355,7 +410,7 @@ func jsEbitenImageDrawText(vm *VM) (int, error) {
 		return 0, fmt.Errorf("not supported align: %s", alignStr)
 	}
 	// TODO: Composition mode?
-	if err := vm.font.drawText(img, text, size, lineWidth, x, y, maxWidth, align, color.NRGBA{r, g, b, a}); err != nil {
+	if err := vm.font.drawText(vm.renderer, img, text, size, lineWidth, x, y, maxWidth, align, color.NRGBA{r, g, b, a}); err != nil {
 		return 0, err
 	}
 	return 0, nil
@@ -380,7 +435,7 @@ func jsEbitenMeasureText(vm *VM) (int, error) {
 func jsEbitenImageDrawImage(vm *VM) (int, error) {
 	dst := vm.getEbitenImage(0)
 	src := vm.getEbitenImage(1)
-	op, err := vm.getEbitenDrawImageOptions(2)
+	op, err := vm.getDrawImageOptions(2)
 	if err != nil {
 		return 0, err
 	}
@@ -424,7 +479,11 @@ func (vm *VM) initEbitenImage() error {
 		return err
 	}
 	vm.context.Pop()
-	if _, err := vm.context.PushGlobalGoFunction("_gophermv_loadEbitenImage", wrapFunc(jsLoadEbitenImage, vm)); err != nil {
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_requestImage", wrapFunc(jsRequestImage, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_waitImage", wrapFunc(jsWaitImage, vm)); err != nil {
 		return err
 	}
 	vm.context.Pop()
@@ -0,0 +1,138 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"sort"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// KeyEvent is a keyboard event dispatched to the game.
+type KeyEvent struct {
+	Frame   int    `json:"frame"`
+	Type    string `json:"type"`
+	KeyCode int    `json:"keyCode"`
+}
+
+// Input is a source of key events dispatched to the game.
+type Input interface {
+	// KeyEvents returns the key events to dispatch at the given frame.
+	KeyEvents(frame int) []*KeyEvent
+}
+
+var (
+	keyCodes = map[ebiten.Key]int{
+		ebiten.KeyTab:      9,
+		ebiten.KeyEnter:    13,
+		ebiten.KeyShift:    16,
+		ebiten.KeyControl:  17,
+		ebiten.KeyAlt:      18,
+		ebiten.KeyEscape:   27,
+		ebiten.KeySpace:    32,
+		ebiten.KeyPageUp:   33,
+		ebiten.KeyPageDown: 34,
+		ebiten.KeyLeft:     37,
+		ebiten.KeyUp:       38,
+		ebiten.KeyRight:    39,
+		ebiten.KeyDown:     40,
+		ebiten.KeyInsert:   45,
+		ebiten.KeyQ:        81,
+		ebiten.KeyW:        87,
+		ebiten.KeyX:        88,
+		ebiten.KeyZ:        90,
+		ebiten.KeyF9:       120,
+	}
+)
+
+type keysByKeyCode []ebiten.Key
+
+func (k keysByKeyCode) Len() int           { return len(k) }
+func (k keysByKeyCode) Less(i, j int) bool { return keyCodes[k[i]] < keyCodes[k[j]] }
+func (k keysByKeyCode) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+
+var (
+	// sortedKeys is the keys of keyCodes in a fixed order so that key events are dispatched deterministically.
+	sortedKeys []ebiten.Key
+)
+
+func init() {
+	for key := range keyCodes {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Sort(keysByKeyCode(sortedKeys))
+}
+
+type keyboardInput struct {
+	keyStates map[ebiten.Key]int
+}
+
+// NewKeyboardInput returns an Input polling the keyboard state via Ebiten.
+func NewKeyboardInput() Input {
+	return &keyboardInput{
+		keyStates: map[ebiten.Key]int{},
+	}
+}
+
+func (k *keyboardInput) KeyEvents(frame int) []*KeyEvent {
+	events := []*KeyEvent{}
+	for _, key := range sortedKeys {
+		if ebiten.IsKeyPressed(key) {
+			if k.keyStates[key] == 0 {
+				events = append(events, &KeyEvent{
+					Frame:   frame,
+					Type:    "keydown",
+					KeyCode: keyCodes[key],
+				})
+			}
+			k.keyStates[key]++
+		} else {
+			if k.keyStates[key] != 0 {
+				events = append(events, &KeyEvent{
+					Frame:   frame,
+					Type:    "keyup",
+					KeyCode: keyCodes[key],
+				})
+			}
+			k.keyStates[key] = 0
+		}
+	}
+	return events
+}
+
+type replayInput struct {
+	events []*KeyEvent
+}
+
+// NewReplayInput returns an Input dispatching the given key events at their frames.
+// events must be sorted by frames.
+func NewReplayInput(events []*KeyEvent) Input {
+	return &replayInput{
+		events: events,
+	}
+}
+
+func (r *replayInput) KeyEvents(frame int) []*KeyEvent {
+	events := []*KeyEvent{}
+	for 0 < len(r.events) {
+		e := r.events[0]
+		if frame < e.Frame {
+			break
+		}
+		r.events = r.events[1:]
+		events = append(events, e)
+	}
+	return events
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// SetLogger sets the logger for console output of the game and warnings of the VM.
+// If SetLogger is not called, console output goes to stdout and warnings go to stderr.
+func (vm *VM) SetLogger(logger *log.Logger) {
+	vm.logger = logger
+}
+
+// warnf reports a warning of the VM.
+func (vm *VM) warnf(format string, args ...interface{}) {
+	if vm.logger != nil {
+		vm.logger.Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+func jsLog(vm *VM) (int, error) {
+	msg := vm.context.SafeToString(0)
+	if vm.logger != nil {
+		vm.logger.Print(msg)
+	} else {
+		fmt.Println(msg)
+	}
+	return 0, nil
+}
+
+const consoleSrc = `
+var console = (function() {
+  function log() {
+    _gophermv_log(Array.prototype.join.call(arguments, ' '));
+  }
+  return {log:log,warn:log,error:log,info:log};
+})();
+`
+
+func (vm *VM) initConsole() error {
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_log", wrapFunc(jsLog, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if err := vm.context.PevalString(consoleSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
@@ -0,0 +1,818 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"gopkg.in/olebedev/go-duktape.v2"
+)
+
+// nodeError is an error of Node's fs module. The message starts with the code like ENOENT
+// as Node's does, and the code is set to the error thrown in JavaScript.
+type nodeError struct {
+	code    string
+	desc    string
+	syscall string
+	path    string
+}
+
+func (e *nodeError) Error() string {
+	return fmt.Sprintf("%s: %s, %s '%s'", e.code, e.desc, e.syscall, e.path)
+}
+
+func newNodeError(err error, syscall, name string) error {
+	p := "/" + name
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &nodeError{"ENOENT", "no such file or directory", syscall, p}
+	case errors.Is(err, fs.ErrExist):
+		return &nodeError{"EEXIST", "file already exists", syscall, p}
+	case errors.Is(err, fs.ErrPermission):
+		return &nodeError{"EACCES", "permission denied", syscall, p}
+	}
+	return err
+}
+
+// stringAt returns the string at index in UTF-8, or an empty string if the value is not a string.
+// Unlike GetString, the string can contain NUL.
+func (vm *VM) stringAt(index int) string {
+	if !vm.context.IsString(index) {
+		return ""
+	}
+	vm.context.Dup(index)
+	defer vm.context.Pop()
+	ptr, n := vm.context.ToBuffer(-1)
+	if n == 0 {
+		return ""
+	}
+	return utf8FromCESU8(unsafe.Slice((*byte)(ptr), n))
+}
+
+// pushString pushes the UTF-8 string s. Unlike PushString, s can contain NUL.
+func (vm *VM) pushString(s string) {
+	s = cesu8FromUTF8(s)
+	vm.context.PushLstring(s, len(s))
+}
+
+// surrogateAt returns the surrogate encoded in 3 bytes at b[i:], and whether there is one.
+func surrogateAt(b []byte, i int) (rune, bool) {
+	if len(b) < i+3 || b[i] != 0xed || b[i+1]&0xe0 != 0xa0 || b[i+2]&0xc0 != 0x80 {
+		return 0, false
+	}
+	return rune(b[i]&0x0f)<<12 | rune(b[i+1]&0x3f)<<6 | rune(b[i+2]&0x3f), true
+}
+
+// utf8FromCESU8 converts b in the internal representation of Duktape to UTF-8.
+// Duktape keeps a character outside the BMP as a surrogate pair, and encodes each surrogate in 3 bytes
+// as CESU-8 does. The other bytes, including lone surrogates, are kept.
+func utf8FromCESU8(b []byte) string {
+	if !bytes.Contains(b, []byte{0xed}) {
+		return string(b)
+	}
+	s := make([]byte, 0, len(b))
+	for i := 0; i < len(b); {
+		if r1, ok := surrogateAt(b, i); ok && r1 < 0xdc00 {
+			if r2, ok := surrogateAt(b, i+3); ok && 0xdc00 <= r2 {
+				s = utf8.AppendRune(s, utf16.DecodeRune(r1, r2))
+				i += 6
+				continue
+			}
+		}
+		s = append(s, b[i])
+		i++
+	}
+	return string(s)
+}
+
+// cesu8FromUTF8 converts s in UTF-8 to the internal representation of Duktape. See utf8FromCESU8.
+func cesu8FromUTF8(s string) string {
+	var b []byte
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r <= 0xffff {
+			if b != nil {
+				b = append(b, s[i:i+n]...)
+			}
+			i += n
+			continue
+		}
+		if b == nil {
+			b = append(make([]byte, 0, len(s)+2), s[:i]...)
+		}
+		r1, r2 := utf16.EncodeRune(r)
+		for _, r := range []rune{r1, r2} {
+			b = append(b, 0xe0|byte(r>>12), 0x80|byte(r>>6)&0x3f, 0x80|byte(r)&0x3f)
+		}
+		i += n
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
+
+// jsQuit ends the game as nw.App.quit and process.exit do. The running task is finished,
+// and then Run and RunHeadless return without an error.
+func jsQuit(vm *VM) (int, error) {
+	vm.terminate()
+	return 0, nil
+}
+
+// nodePath returns the name in the game's filesystem of the path that scripts use.
+// The root directory '/' of the paths is the game's directory.
+func nodePath(p string) string {
+	name := path.Clean("/" + p)[1:]
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// nwjsStat returns the file info of name. Files written by scripts precede the game's files.
+func (vm *VM) nwjsStat(name string) (fs.FileInfo, error) {
+	if vm.nwjsFS != nil {
+		fi, err := fs.Stat(vm.nwjsFS, name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return fi, err
+		}
+	}
+	return fs.Stat(vm.fs, name)
+}
+
+// nwjsFile returns the path on the local filesystem where the file name is written.
+// The parent directory of name must exist.
+func (vm *VM) nwjsFile(syscall, name string) (string, error) {
+	if vm.nwjsFS == nil {
+		return "", &nodeError{"EROFS", "read-only file system", syscall, "/" + name}
+	}
+	fi, err := vm.nwjsStat(path.Dir(name))
+	if err != nil {
+		return "", newNodeError(err, syscall, name)
+	}
+	if !fi.IsDir() {
+		return "", &nodeError{"ENOTDIR", "not a directory", syscall, "/" + name}
+	}
+	p, err := vm.nwjsFS.Resolve(name)
+	if err != nil {
+		return "", newNodeError(err, syscall, name)
+	}
+	return p, nil
+}
+
+func jsFSReadFile(vm *VM) (int, error) {
+	name := nodePath(vm.stringAt(0))
+	binary := vm.context.GetBoolean(1)
+	fi, err := vm.nwjsStat(name)
+	if err != nil {
+		return 0, newNodeError(err, "open", name)
+	}
+	if fi.IsDir() {
+		return 0, &nodeError{"EISDIR", "illegal operation on a directory", "read", "/" + name}
+	}
+	var content []byte
+	if vm.nwjsFS != nil {
+		content, err = fs.ReadFile(vm.nwjsFS, name)
+	}
+	if vm.nwjsFS == nil || errors.Is(err, fs.ErrNotExist) {
+		content, err = fs.ReadFile(vm.fs, name)
+	}
+	if err != nil {
+		return 0, newNodeError(err, "open", name)
+	}
+	if binary {
+		vm.pushUint8Array(content)
+		return 1, nil
+	}
+	vm.pushString(string(content))
+	return 1, nil
+}
+
+func jsFSWriteFile(vm *VM) (int, error) {
+	name := nodePath(vm.stringAt(0))
+	data := vm.stringAt(1)
+	binary := vm.context.GetBoolean(2)
+	p, err := vm.nwjsFile("open", name)
+	if err != nil {
+		return 0, err
+	}
+	if fi, err := vm.nwjsStat(name); err == nil && fi.IsDir() {
+		return 0, &nodeError{"EISDIR", "illegal operation on a directory", "open", "/" + name}
+	}
+	content := []byte(data)
+	if binary {
+		// Binary data is passed as a hex string since the data of a Uint8Array can't be read by the binding.
+		c, err := hex.DecodeString(data)
+		if err != nil {
+			return 0, err
+		}
+		content = c
+	}
+	// The directory might exist only in the game's filesystem.
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return 0, newNodeError(err, "open", name)
+	}
+	if err := os.WriteFile(p, content, 0644); err != nil {
+		return 0, newNodeError(err, "open", name)
+	}
+	return 0, nil
+}
+
+// jsFSStat returns 'file' or 'directory', or null if the file doesn't exist.
+func jsFSStat(vm *VM) (int, error) {
+	fi, err := vm.nwjsStat(nodePath(vm.stringAt(0)))
+	if err != nil {
+		vm.context.PushNull()
+		return 1, nil
+	}
+	if fi.IsDir() {
+		vm.context.PushString("directory")
+		return 1, nil
+	}
+	vm.context.PushString("file")
+	return 1, nil
+}
+
+func jsFSMkdir(vm *VM) (int, error) {
+	name := nodePath(vm.stringAt(0))
+	recursive := vm.context.GetBoolean(1)
+	if fi, err := vm.nwjsStat(name); err == nil {
+		if recursive && fi.IsDir() {
+			return 0, nil
+		}
+		return 0, &nodeError{"EEXIST", "file already exists", "mkdir", "/" + name}
+	}
+	var p string
+	if recursive {
+		if vm.nwjsFS == nil {
+			return 0, &nodeError{"EROFS", "read-only file system", "mkdir", "/" + name}
+		}
+		r, err := vm.nwjsFS.Resolve(name)
+		if err != nil {
+			return 0, newNodeError(err, "mkdir", name)
+		}
+		p = r
+	} else {
+		r, err := vm.nwjsFile("mkdir", name)
+		if err != nil {
+			return 0, err
+		}
+		p = r
+	}
+	if err := os.MkdirAll(p, 0755); err != nil {
+		return 0, newNodeError(err, "mkdir", name)
+	}
+	return 0, nil
+}
+
+func jsFSReaddir(vm *VM) (int, error) {
+	name := nodePath(vm.stringAt(0))
+	fi, err := vm.nwjsStat(name)
+	if err != nil {
+		return 0, newNodeError(err, "scandir", name)
+	}
+	if !fi.IsDir() {
+		return 0, &nodeError{"ENOTDIR", "not a directory", "scandir", "/" + name}
+	}
+	fss := []fs.FS{vm.fs}
+	if vm.nwjsFS != nil {
+		fss = append(fss, vm.nwjsFS)
+	}
+	names := map[string]struct{}{}
+	for _, fsys := range fss {
+		es, err := fs.ReadDir(fsys, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, newNodeError(err, "scandir", name)
+		}
+		for _, e := range es {
+			names[e.Name()] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+	vm.context.PushArray()
+	for i, n := range sorted {
+		vm.context.PushString(n)
+		vm.context.PutPropIndex(-2, uint(i))
+	}
+	return 1, nil
+}
+
+func jsFSUnlink(vm *VM) (int, error) {
+	name := nodePath(vm.stringAt(0))
+	fi, err := vm.nwjsStat(name)
+	if err != nil {
+		return 0, newNodeError(err, "unlink", name)
+	}
+	if fi.IsDir() {
+		return 0, &nodeError{"EISDIR", "illegal operation on a directory", "unlink", "/" + name}
+	}
+	p, err := vm.nwjsFile("unlink", name)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The file exists only in the game's filesystem, which is not writable.
+			return 0, &nodeError{"EROFS", "read-only file system", "unlink", "/" + name}
+		}
+		return 0, newNodeError(err, "unlink", name)
+	}
+	return 0, nil
+}
+
+// jsCompileModule returns the function of a CommonJS module.
+func jsCompileModule(vm *VM) (int, error) {
+	filename := vm.stringAt(0)
+	src := vm.stringAt(1)
+	vm.pushString(filename)
+	// The wrapper is on the first line so that the line numbers in errors don't change.
+	src = "(function (exports, require, module, __filename, __dirname) {" + src + "\n})"
+	if err := vm.context.PcompileLstringFilename(duktape.CompileEval, src, len(src)); err != nil {
+		return 0, err
+	}
+	if err := vm.intToError(vm.context.Pcall(0)); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// nwjsPlatform returns process.platform of Node.
+func nwjsPlatform() string {
+	if runtime.GOOS == "windows" {
+		return "win32"
+	}
+	return runtime.GOOS
+}
+
+const nwjsSrc = `
+(function(global) {
+  // call calls a Go function of fs and sets the code like ENOENT to the error.
+  function call(f, args) {
+    try {
+      return f.apply(null, args);
+    } catch (e) {
+      var m = /^(E[A-Z]+): /.exec(e.message);
+      if (m) {
+        e.code = m[1];
+      }
+      throw e;
+    }
+  }
+
+  function normalizeString(p) {
+    var parts = [];
+    var segs = p.split('/');
+    for (var i = 0; i < segs.length; i++) {
+      var s = segs[i];
+      if (s === '' || s === '.') {
+        continue;
+      }
+      if (s === '..') {
+        if (parts.length && parts[parts.length - 1] !== '..') {
+          parts.pop();
+        } else if (p.charAt(0) !== '/') {
+          parts.push('..');
+        }
+        continue;
+      }
+      parts.push(s);
+    }
+    return parts.join('/');
+  }
+
+  var path = {
+    sep: '/',
+    delimiter: ':',
+    isAbsolute: function(p) {
+      return String(p).charAt(0) === '/';
+    },
+    normalize: function(p) {
+      p = String(p);
+      if (p === '') {
+        return '.';
+      }
+      var abs = p.charAt(0) === '/';
+      var trailing = p.charAt(p.length - 1) === '/';
+      var r = normalizeString(p);
+      if (r === '' && !abs) {
+        r = '.';
+      }
+      if (r !== '' && trailing) {
+        r += '/';
+      }
+      return abs ? '/' + r : r;
+    },
+    join: function() {
+      var ps = [];
+      for (var i = 0; i < arguments.length; i++) {
+        if (arguments[i] !== '') {
+          ps.push(String(arguments[i]));
+        }
+      }
+      if (ps.length === 0) {
+        return '.';
+      }
+      return path.normalize(ps.join('/'));
+    },
+    resolve: function() {
+      var p = '';
+      for (var i = arguments.length - 1; i >= 0 && p.charAt(0) !== '/'; i--) {
+        var s = String(arguments[i]);
+        if (s !== '') {
+          p = p === '' ? s : s + '/' + p;
+        }
+      }
+      if (p.charAt(0) !== '/') {
+        p = process.cwd() + '/' + p;
+      }
+      return '/' + normalizeString(p);
+    },
+    relative: function(from, to) {
+      var f = path.resolve(from).split('/').slice(1);
+      var t = path.resolve(to).split('/').slice(1);
+      if (f[0] === '') {
+        f = [];
+      }
+      if (t[0] === '') {
+        t = [];
+      }
+      var i = 0;
+      while (i < f.length && i < t.length && f[i] === t[i]) {
+        i++;
+      }
+      var r = [];
+      for (var j = i; j < f.length; j++) {
+        r.push('..');
+      }
+      return r.concat(t.slice(i)).join('/');
+    },
+    dirname: function(p) {
+      p = String(p);
+      if (p === '') {
+        return '.';
+      }
+      var abs = p.charAt(0) === '/';
+      p = p.replace(/\/+$/, '');
+      var i = p.lastIndexOf('/');
+      if (i < 0) {
+        return abs ? '/' : '.';
+      }
+      p = p.slice(0, i).replace(/\/+$/, '');
+      return p === '' ? '/' : p;
+    },
+    basename: function(p, ext) {
+      p = String(p).replace(/\/+$/, '');
+      var b = p.slice(p.lastIndexOf('/') + 1);
+      if (ext !== undefined && ext !== b && b.slice(b.length - ext.length) === ext) {
+        b = b.slice(0, b.length - ext.length);
+      }
+      return b;
+    },
+    extname: function(p) {
+      var b = path.basename(p);
+      var i = b.lastIndexOf('.');
+      if (i <= 0) {
+        return '';
+      }
+      return b.slice(i);
+    },
+  };
+  path.posix = path;
+
+  function encodingOf(options) {
+    if (typeof options === 'string') {
+      return options;
+    }
+    if (options && options.encoding) {
+      return options.encoding;
+    }
+    return null;
+  }
+
+  function decodeUTF8(bytes) {
+    var s = '';
+    for (var i = 0; i < bytes.length;) {
+      var c = bytes[i++];
+      var n = 0;
+      if (c >= 0xf0 && c < 0xf8) {
+        n = 3;
+        c &= 0x07;
+      } else if (c >= 0xe0) {
+        n = 2;
+        c &= 0x0f;
+      } else if (c >= 0xc0) {
+        n = 1;
+        c &= 0x1f;
+      } else if (c >= 0x80) {
+        c = 0xfffd;
+      }
+      for (var j = 0; j < n; j++) {
+        if (i >= bytes.length || (bytes[i] & 0xc0) !== 0x80) {
+          c = 0xfffd;
+          break;
+        }
+        c = (c << 6) | (bytes[i++] & 0x3f);
+      }
+      if (c > 0xffff) {
+        c -= 0x10000;
+        s += String.fromCharCode(0xd800 + (c >> 10), 0xdc00 + (c & 0x3ff));
+      } else {
+        s += String.fromCharCode(c);
+      }
+    }
+    return s;
+  }
+
+  function bufferToString() {
+    return decodeUTF8(this);
+  }
+
+  function bytesToHex(data) {
+    var s = '';
+    for (var i = 0; i < data.length; i++) {
+      s += (0x100 | (data[i] & 0xff)).toString(16).slice(1);
+    }
+    return s;
+  }
+
+  var fs = {
+    readFileSync: function(p, options) {
+      p = path.resolve(String(p));
+      if (encodingOf(options) !== null) {
+        return call(_gophermv_fsReadFile, [p, false]);
+      }
+      var buf = call(_gophermv_fsReadFile, [p, true]);
+      buf.toString = bufferToString;
+      return buf;
+    },
+    writeFileSync: function(p, data) {
+      p = path.resolve(String(p));
+      if (data instanceof ArrayBuffer) {
+        data = new Uint8Array(data);
+      }
+      if (data && typeof data === 'object' && typeof data.length === 'number') {
+        call(_gophermv_fsWriteFile, [p, bytesToHex(data), true]);
+        return;
+      }
+      call(_gophermv_fsWriteFile, [p, String(data), false]);
+    },
+    existsSync: function(p) {
+      return _gophermv_fsStat(path.resolve(String(p))) !== null;
+    },
+    mkdirSync: function(p, options) {
+      var recursive = !!(options && typeof options === 'object' && options.recursive);
+      call(_gophermv_fsMkdir, [path.resolve(String(p)), recursive]);
+    },
+    readdirSync: function(p) {
+      return call(_gophermv_fsReaddir, [path.resolve(String(p))]);
+    },
+    unlinkSync: function(p) {
+      call(_gophermv_fsUnlink, [path.resolve(String(p))]);
+    },
+  };
+
+  function Window() {
+    this.title = document.title;
+    this.isFullscreen = false;
+    this.zoomLevel = 0;
+    this.menu = null;
+  }
+
+  // TODO: Implement these
+  Window.prototype.on = function() {};
+  Window.prototype.removeAllListeners = function() {};
+  Window.prototype.showDevTools = function() {};
+  Window.prototype.closeDevTools = function() {};
+  Window.prototype.focus = function() {};
+  Window.prototype.close = function() {};
+  Window.prototype.maximize = function() {};
+  Window.prototype.minimize = function() {};
+  Window.prototype.restore = function() {};
+  Window.prototype.moveTo = function() {};
+  Window.prototype.resizeTo = function() {};
+  Window.prototype.setPosition = function() {};
+  Window.prototype.enterFullscreen = function() {};
+  Window.prototype.leaveFullscreen = function() {};
+  Window.prototype.toggleFullscreen = function() {};
+
+  // Menus are never shown. SceneManager.initNwjs creates the menu bar on macOS.
+  function Menu(options) {
+    this.type = (options && options.type) || 'contextmenu';
+    this.items = [];
+  }
+
+  Menu.prototype.append = function(item) {
+    this.items.push(item);
+  };
+  Menu.prototype.insert = function(item, i) {
+    this.items.splice(i, 0, item);
+  };
+  Menu.prototype.remove = function(item) {
+    var i = this.items.indexOf(item);
+    if (i !== -1) {
+      this.items.splice(i, 1);
+    }
+  };
+  Menu.prototype.removeAt = function(i) {
+    this.items.splice(i, 1);
+  };
+  Menu.prototype.popup = function() {};
+  Menu.prototype.createMacBuiltin = function() {};
+
+  function MenuItem(options) {
+    options = options || {};
+    this.type = options.type || 'normal';
+    this.label = options.label || '';
+    this.icon = options.icon || '';
+    this.tooltip = options.tooltip || '';
+    this.checked = !!options.checked;
+    this.enabled = options.enabled !== false;
+    this.submenu = options.submenu || null;
+    this.click = options.click || null;
+    this.key = options.key || '';
+    this.modifiers = options.modifiers || '';
+  }
+
+  var currentWindow = null;
+
+  var nw = {
+    Window: {
+      get: function() {
+        if (!currentWindow) {
+          currentWindow = new Window();
+        }
+        return currentWindow;
+      },
+    },
+    Menu: Menu,
+    MenuItem: MenuItem,
+    App: {
+      argv: [],
+      dataPath: '/',
+      quit: function() {
+        _gophermv_quit();
+      },
+    },
+  };
+
+  var builtinModules = {
+    fs: fs,
+    path: path,
+    'nw.gui': nw,
+  };
+
+  var modules = {};
+
+  function findModule(p) {
+    var candidates = [p, p + '.js', p + '.json', p + '/index.js'];
+    for (var i = 0; i < candidates.length; i++) {
+      if (_gophermv_fsStat(candidates[i]) === 'file') {
+        return candidates[i];
+      }
+    }
+    return null;
+  }
+
+  // newRequire returns require for a module in dir.
+  function newRequire(dir) {
+    return function require(id) {
+      id = String(id);
+      if (Object.prototype.hasOwnProperty.call(builtinModules, id)) {
+        return builtinModules[id];
+      }
+      var filename = null;
+      if (/^\.{0,2}\//.test(id)) {
+        filename = findModule(path.resolve(dir, id));
+      }
+      if (filename === null) {
+        var err = new Error("Cannot find module '" + id + "'");
+        err.code = 'MODULE_NOT_FOUND';
+        throw err;
+      }
+      if (Object.prototype.hasOwnProperty.call(modules, filename)) {
+        return modules[filename].exports;
+      }
+      var module = {
+        id: filename,
+        filename: filename,
+        exports: {},
+        loaded: false,
+      };
+      modules[filename] = module;
+      var src = fs.readFileSync(filename, 'utf8');
+      try {
+        if (path.extname(filename) === '.json') {
+          module.exports = JSON.parse(src);
+        } else {
+          var f = _gophermv_compileModule(filename.slice(1), src);
+          f.call(module.exports, module.exports, newRequire(path.dirname(filename)), module, filename, path.dirname(filename));
+        }
+      } catch (e) {
+        delete modules[filename];
+        throw e;
+      }
+      module.loaded = true;
+      return module.exports;
+    };
+  }
+
+  global.require = newRequire('/');
+  global.nw = nw;
+  global.process = {
+    argv: ['gophermv'],
+    env: {},
+    mainModule: {
+      filename: '/index.html',
+    },
+    cwd: function() {
+      return '/';
+    },
+    exit: function(code) {
+      _gophermv_quit();
+    },
+    // TODO: Implement this
+    on: function() {},
+  };
+})(this);
+`
+
+// EnableNWJS provides require, process and nw to scripts as nw.js does, so that the code for the
+// desktop version of games, like saving files in StorageManager, runs.
+// require supports fs and path of Node, CommonJS modules in the game's files and nw.gui.
+//
+// The paths that scripts use are rooted at the game's directory, which is '/'.
+// Scripts can't access files out of it. Files are read from the game's filesystem and dir,
+// and written in dir. Files in dir take precedence over the game's files.
+// If dir is nil, writing files fails.
+func (vm *VM) EnableNWJS(dir *Sandbox) error {
+	vm.nwjsFS = dir
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_fsReadFile", wrapFunc(jsFSReadFile, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_fsWriteFile", wrapFunc(jsFSWriteFile, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_fsStat", wrapFunc(jsFSStat, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_fsMkdir", wrapFunc(jsFSMkdir, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_fsReaddir", wrapFunc(jsFSReaddir, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_fsUnlink", wrapFunc(jsFSUnlink, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_compileModule", wrapFunc(jsCompileModule, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_quit", wrapFunc(jsQuit, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if err := vm.context.PevalString(nwjsSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	vm.context.GetGlobalString("process")
+	vm.context.PushString(nwjsPlatform())
+	vm.context.PutPropString(-2, "platform")
+	vm.context.Pop()
+	return nil
+}
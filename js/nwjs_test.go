@@ -0,0 +1,210 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// newTestNWJSVM returns a VM with nw.js's APIs whose game is in the directory of newTestSandbox.
+func newTestNWJSVM(t *testing.T) (vm *VM, root, outside, allowed string) {
+	t.Helper()
+	s, root, outside, allowed := newTestSandbox(t)
+	writeTestFile(t, filepath.Join(root, indexHTMLFile), "<html></html>")
+	if err := os.Mkdir(filepath.Join(root, "fonts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "fonts", "mplus-1m-regular.ttf"), goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	vm, err := NewVM(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(vm.Destroy)
+	if err := vm.EnableNWJS(s); err != nil {
+		t.Fatal(err)
+	}
+	return vm, root, outside, allowed
+}
+
+func TestNWJSPath(t *testing.T) {
+	vm := newTestVM(t, nil)
+	if err := vm.EnableNWJS(nil); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{`path.normalize('/a/b/../c/./d')`, `"/a/c/d"`},
+		{`path.normalize('a//b/')`, `"a/b/"`},
+		{`path.normalize('')`, `"."`},
+		{`path.normalize('./')`, `"./"`},
+		{`path.normalize('../../a')`, `"../../a"`},
+		{`path.normalize('a/../..')`, `".."`},
+		{`path.normalize('/../a')`, `"/a"`},
+		{`path.join()`, `"."`},
+		{`path.join('a', '', '../b')`, `"b"`},
+		{`path.join('/a', 'b/', '..')`, `"/a"`},
+		{`path.join('a', '/b')`, `"a/b"`},
+		{`path.resolve()`, `"/"`},
+		{`path.resolve('a', '/b', 'c')`, `"/b/c"`},
+		{`path.resolve('save', '../www/')`, `"/www"`},
+		{`path.resolve('/a/b/', '..')`, `"/a"`},
+		{`path.resolve('../../a')`, `"/a"`},
+		{`path.relative('/a/b', '/a/c/d')`, `"../c/d"`},
+		{`path.dirname('/a/b/')`, `"/a"`},
+		{`path.dirname('a')`, `"."`},
+		{`path.basename('/a/b.js', '.js')`, `"b"`},
+		{`path.extname('.bashrc')`, `""`},
+	}
+	for _, tc := range testCases {
+		got := runTestScript(t, vm, `var path = require('path'); var result = `+tc.expr+`;`)
+		if got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestNWJSFileRoundTrip(t *testing.T) {
+	vm, _, _, allowed := newTestNWJSVM(t)
+	// save is a symbolic link to the allowed directory.
+	src := `
+var fs = require('fs');
+var text = 'hello\u0000worldあ\ud83d\ude00';
+fs.mkdirSync('save', {recursive: true});
+fs.writeFileSync('save/a.txt', text);
+fs.writeFileSync('/save/b.bin', new Uint8Array([0, 1, 127, 128, 255, 0]));
+var result = [
+  fs.readFileSync('save/a.txt', 'utf8') === text,
+  fs.readFileSync('save/a.txt', 'utf8').length,
+  fs.readFileSync('save/a.txt').toString() === text,
+  Array.prototype.slice.call(fs.readFileSync('save/b.bin')),
+  fs.existsSync('save/b.bin'),
+  fs.readdirSync('save'),
+];
+`
+	got := runTestScript(t, vm, src)
+	if want := `[true,14,true,[0,1,127,128,255,0],true,["a.txt","b.bin","file1.rpgsave"]]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	for name, want := range map[string]string{
+		"a.txt": "hello\x00worldあ😀",
+		"b.bin": "\x00\x01\x7f\x80\xff\x00",
+	} {
+		b, err := os.ReadFile(filepath.Join(allowed, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", name, b, want)
+		}
+	}
+}
+
+func TestCESU8(t *testing.T) {
+	testCases := []struct {
+		utf8  string
+		cesu8 string
+	}{
+		{"", ""},
+		{"abc\x00あ", "abc\x00あ"},
+		{"😀", "\xed\xa0\xbd\xed\xb8\x80"},
+		{"a😀b𠮷", "a\xed\xa0\xbd\xed\xb8\x80b\xed\xa1\x82\xed\xbe\xb7"},
+		// A lone surrogate is kept.
+		{"\xed\xa0\xbd", "\xed\xa0\xbd"},
+	}
+	for _, tc := range testCases {
+		if got := cesu8FromUTF8(tc.utf8); got != tc.cesu8 {
+			t.Errorf("cesu8FromUTF8(%q): got %q, want %q", tc.utf8, got, tc.cesu8)
+		}
+		if got := utf8FromCESU8([]byte(tc.cesu8)); got != tc.utf8 {
+			t.Errorf("utf8FromCESU8(%q): got %q, want %q", tc.cesu8, got, tc.utf8)
+		}
+	}
+}
+
+func TestNWJSOutOfSandbox(t *testing.T) {
+	vm, _, outside, _ := newTestNWJSVM(t)
+	// out is a symbolic link to the directory out of the game's directory.
+	src := `
+var fs = require('fs');
+function code(f) {
+  try {
+    f();
+  } catch (e) {
+    return e.code;
+  }
+  return 'ok';
+}
+var result = [
+  code(function() { fs.readFileSync('out/secret.txt'); }),
+  code(function() { fs.writeFileSync('out/new.txt', 'x'); }),
+  code(function() { fs.readdirSync('out'); }),
+  code(function() { fs.readFileSync('../../secret.txt'); }),
+  code(function() { fs.readFileSync('/a.txt'); }),
+  fs.existsSync('out/secret.txt'),
+];
+`
+	got := runTestScript(t, vm, src)
+	if want := `["EACCES","EACCES","EACCES","ENOENT","ok",false]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a file out of the sandbox must not be written: %v", err)
+	}
+}
+
+func TestNWJSQuit(t *testing.T) {
+	for _, quit := range []string{"nw.App.quit()", "process.exit(0)"} {
+		vm, root, _, _ := newTestNWJSVM(t)
+		src := testScreenSrc + `
+var frames = 0;
+requestAnimationFrame(function update() {
+  frames++;
+  if (frames === 3) {
+    ` + quit + `;
+  }
+  requestAnimationFrame(update);
+});
+`
+		if err := os.MkdirAll(filepath.Join(root, "js"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, filepath.Join(root, "js", "test.js"), src)
+		vm.Enqueue("js/test.js")
+		var frames int
+		vm.SetFrameHandler(func(frame int, screen Image) error {
+			frames++
+			return nil
+		})
+		if err := vm.RunHeadless(100); err != nil {
+			t.Errorf("%s: RunHeadless: %v", quit, err)
+			continue
+		}
+		if frames != 3 {
+			t.Errorf("%s: frames: got %d, want 3", quit, frames)
+		}
+	}
+}
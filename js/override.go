@@ -0,0 +1,118 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+const (
+	overrideBeforeSuffix = ".before.js"
+	overrideAfterSuffix  = ".after.js"
+	overrideSkipSuffix   = ".skip"
+)
+
+// override is a set of rules to modify a script of the game.
+type override struct {
+	skip   bool
+	before []func(vm *VM) error
+	after  []func(vm *VM) error
+}
+
+func (vm *VM) override(script string) *override {
+	script = fsPath(script)
+	o, ok := vm.overrides[script]
+	if !ok {
+		o = &override{}
+		vm.overrides[script] = o
+	}
+	return o
+}
+
+func (vm *VM) initOverrides() {
+	vm.overrides = map[string]*override{}
+
+	// Why: Some elements are not defined.
+	vm.SkipScript("js/libs/fpsmeter.js")
+
+	o := vm.override("js/rpg_core.js")
+	o.after = append(o.after, (*VM).overrideCoreClasses)
+	o = vm.override("js/rpg_managers.js")
+	o.after = append(o.after, (*VM).overrideManagerClasses)
+}
+
+// SkipScript makes the script at path not executed.
+// Patches of the script are still executed.
+func (vm *VM) SkipScript(path string) {
+	vm.override(path).skip = true
+}
+
+// PatchScriptBefore adds JavaScript src executed just before the script at path.
+// name is used as the file name in errors.
+func (vm *VM) PatchScriptBefore(path string, name string, src string) {
+	vm.sources[name] = src
+	o := vm.override(path)
+	o.before = append(o.before, func(vm *VM) error {
+		return vm.execSource(name, src)
+	})
+}
+
+// PatchScriptAfter adds JavaScript src executed just after the script at path.
+// name is used as the file name in errors.
+func (vm *VM) PatchScriptAfter(path string, name string, src string) {
+	vm.sources[name] = src
+	o := vm.override(path)
+	o.after = append(o.after, func(vm *VM) error {
+		return vm.execSource(name, src)
+	})
+}
+
+// LoadOverrides loads the rules to modify scripts from fsys.
+// The files in fsys are paths of the scripts with suffixes:
+//
+//	js/plugins/Foo.before.js  executed before js/plugins/Foo.js
+//	js/plugins/Foo.after.js   executed after js/plugins/Foo.js
+//	js/plugins/Foo.skip       js/plugins/Foo.js is not executed
+//
+// dir is the name of fsys used as the directory of the patches in errors.
+func (vm *VM) LoadOverrides(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch {
+		case strings.HasSuffix(p, overrideBeforeSuffix):
+			src, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			vm.PatchScriptBefore(strings.TrimSuffix(p, overrideBeforeSuffix)+".js", path.Join(dir, p), string(src))
+		case strings.HasSuffix(p, overrideAfterSuffix):
+			src, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			vm.PatchScriptAfter(strings.TrimSuffix(p, overrideAfterSuffix)+".js", path.Join(dir, p), string(src))
+		case strings.HasSuffix(p, overrideSkipSuffix):
+			vm.SkipScript(strings.TrimSuffix(p, overrideSkipSuffix) + ".js")
+		}
+		return nil
+	})
+}
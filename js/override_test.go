@@ -0,0 +1,138 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// testRPGManagersSrc is a minimal js/rpg_managers.js that the built-in patches can modify.
+const testRPGManagersSrc = `
+var SceneManager = {};
+
+var PluginManager = {};
+
+PluginManager.setup = function(plugins) {
+  plugins.forEach(function(plugin) {
+    if (plugin.status) {
+      var script = document.createElement('script');
+      script.src = 'js/plugins/' + plugin.name + '.js';
+      document.body.appendChild(script);
+    }
+  });
+};
+`
+
+// runTestScripts executes the scripts in order with the overrides of vm and returns the value of
+// the global variable result as JSON.
+func runTestScripts(t *testing.T, vm *VM, scripts ...string) string {
+	t.Helper()
+	if err := vm.execSource("init.js", "var result = [];"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range scripts {
+		if err := vm.exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := vm.Get("result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.String()
+}
+
+func TestOverrideOrder(t *testing.T) {
+	vm := newTestVM(t, fstest.MapFS{
+		"js/a.js": {Data: []byte("result.push('a');")},
+		"js/b.js": {Data: []byte("result.push('b');")},
+	})
+	overrides := fstest.MapFS{
+		"js/a.before.js": {Data: []byte("result.push('a.before.js');")},
+		"js/a.after.js":  {Data: []byte("result.push('a.after.js');")},
+		"js/b.skip":      {},
+		"js/b.after.js":  {Data: []byte("result.push('b.after.js');")},
+	}
+	if err := vm.LoadOverrides(overrides, "overrides"); err != nil {
+		t.Fatal(err)
+	}
+	// Patches added later run later, and the paths are normalized.
+	vm.PatchScriptBefore("./js/a.js", "before2.js", "result.push('before2');")
+	vm.PatchScriptAfter("js/a.js", "after2.js", "result.push('after2');")
+
+	got := runTestScripts(t, vm, "js/a.js", "js/b.js")
+	want := `["a.before.js","before2","a","a.after.js","after2","b.after.js"]`
+	if got != want {
+		t.Errorf("result: got %s, want %s", got, want)
+	}
+}
+
+func TestOverrideBuiltIn(t *testing.T) {
+	vm := newTestVM(t, fstest.MapFS{
+		"js/libs/fpsmeter.js": {Data: []byte("result.push('fpsmeter');")},
+		"js/rpg_managers.js":  {Data: []byte(testRPGManagersSrc)},
+	})
+	overrides := fstest.MapFS{
+		"js/libs/fpsmeter.before.js": {Data: []byte("result.push('fpsmeter.before.js');")},
+		// The built-in patch is applied before the loaded one.
+		"js/rpg_managers.after.js": {Data: []byte("result.push(typeof _gophermv_addPlugin);")},
+	}
+	if err := vm.LoadOverrides(overrides, "overrides"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := runTestScripts(t, vm, "js/libs/fpsmeter.js", "js/rpg_managers.js")
+	want := `["fpsmeter.before.js","function"]`
+	if got != want {
+		t.Errorf("result: got %s, want %s", got, want)
+	}
+}
+
+func TestOverrideSkipRemovesScript(t *testing.T) {
+	vm := newTestVM(t, fstest.MapFS{
+		"js/a.js": {Data: []byte("result.push('a');")},
+		"js/b.js": {Data: []byte("result.push('b');")},
+	})
+	vm.PatchScriptBefore("js/a.js", "before.js", "result.push('before');")
+	vm.SkipScript("js/a.js")
+
+	got := runTestScripts(t, vm, "js/a.js", "js/b.js")
+	want := `["before","b"]`
+	if got != want {
+		t.Errorf("result: got %s, want %s", got, want)
+	}
+}
+
+func TestOverrideError(t *testing.T) {
+	vm := newTestVM(t, fstest.MapFS{
+		"js/a.js": {Data: []byte("result.push('a');")},
+	})
+	vm.PatchScriptBefore("js/a.js", "before.js", "throw new Error('before');")
+	if err := vm.execSource("init.js", "var result = [];"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.exec("js/a.js"); err == nil {
+		t.Fatal("exec: got nil, want an error")
+	}
+	v, err := vm.Get("result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The script is not executed after its patch fails.
+	if got, want := v.String(), `[]`; got != want {
+		t.Errorf("result: got %s, want %s", got, want)
+	}
+}
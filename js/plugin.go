@@ -0,0 +1,116 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// pluginPath is the directory of plugins. This is the same as PluginManager._path.
+const pluginPath = "js/plugins/"
+
+// PluginStatus represents whether a plugin is loaded.
+type PluginStatus int
+
+const (
+	PluginNotLoaded PluginStatus = iota
+	PluginLoaded
+	PluginFailed
+	PluginSkipped
+)
+
+func (s PluginStatus) String() string {
+	switch s {
+	case PluginNotLoaded:
+		return "not loaded"
+	case PluginLoaded:
+		return "loaded"
+	case PluginFailed:
+		return "failed"
+	case PluginSkipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
+// Plugin represents a plugin of the game listed in js/plugins.js.
+type Plugin struct {
+	Name       string
+	Parameters map[string]string
+
+	// Enabled reports whether the plugin is turned on in js/plugins.js.
+	// A disabled plugin is skipped.
+	Enabled bool
+
+	Status PluginStatus
+
+	// Err is the error when the plugin failed to load.
+	Err error
+}
+
+// Plugins returns the plugins of the game in the order of js/plugins.js.
+// The plugins are known after js/plugins.js is executed.
+func (vm *VM) Plugins() []*Plugin {
+	return vm.plugins
+}
+
+func jsAddPlugin(vm *VM) (int, error) {
+	name := vm.context.GetString(0)
+	enabled := vm.context.GetBoolean(1)
+	params := map[string]string{}
+	// Parameters of a plugin are strings, but ignore the parameters if they are not.
+	_ = json.Unmarshal([]byte(vm.context.GetString(2)), &params)
+	path := fsPath(pluginPath + name + ".js")
+	if _, ok := vm.pluginsByPath[path]; ok {
+		return 0, nil
+	}
+	p := &Plugin{
+		Name:       name,
+		Parameters: params,
+		Enabled:    enabled,
+	}
+	if !enabled {
+		p.Status = PluginSkipped
+	}
+	vm.plugins = append(vm.plugins, p)
+	vm.pluginsByPath[path] = p
+	return 0, nil
+}
+
+// execScript executes the script. An error of a plugin is reported and ignored so that the game continues.
+func (vm *VM) execScript(filename string) error {
+	p, ok := vm.pluginsByPath[fsPath(filename)]
+	if !ok {
+		return vm.exec(filename)
+	}
+	top := vm.context.GetTop()
+	err := vm.exec(filename)
+	if n := vm.context.GetTop(); n != top {
+		return fmt.Errorf("js: the stack top changed from %d to %d by loading plugin %s", top, n, p.Name)
+	}
+	if err != nil {
+		p.Status = PluginFailed
+		p.Err = vm.toError(err)
+		vm.warnf("plugin %s failed to load and is ignored:\n%v\n", p.Name, p.Err)
+		return nil
+	}
+	if o, ok := vm.overrides[fsPath(filename)]; ok && o.skip {
+		p.Status = PluginSkipped
+		return nil
+	}
+	p.Status = PluginLoaded
+	return nil
+}
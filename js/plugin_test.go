@@ -0,0 +1,95 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPluginFailureIsolated(t *testing.T) {
+	files := fstest.MapFS{
+		"js/rpg_managers.js": {Data: []byte(testRPGManagersSrc)},
+		"js/main.js": {Data: []byte(`PluginManager.setup([
+  {name: 'A', status: true, parameters: {x: '1'}},
+  {name: 'B', status: true, parameters: {}},
+  {name: 'C', status: false, parameters: {}},
+  {name: 'D', status: true, parameters: {}},
+  {name: 'E', status: true, parameters: {}},
+]);`)},
+		"js/plugins/A.js": {Data: []byte("result.push('A');")},
+		"js/plugins/B.js": {Data: []byte("result.push('B'); throw new TypeError('broken');")},
+		"js/plugins/C.js": {Data: []byte("result.push('C');")},
+		"js/plugins/D.js": {Data: []byte("result.push('D');")},
+		"js/plugins/E.js": {Data: []byte("result.push('E');")},
+	}
+	src := `
+var result = [];
+['js/rpg_managers.js', 'js/main.js'].forEach(function(src) {
+  var script = document.createElement('script');
+  script.src = src;
+  document.body.appendChild(script);
+});
+`
+	var vm *VM
+	got, out := runTestGameWith(t, files, src, 1, func(v *VM) error {
+		vm = v
+		vm.SkipScript("js/plugins/E.js")
+		return nil
+	})
+	if want := `["A","B","D"]`; got != want {
+		t.Errorf("result: got %s, want %s", got, want)
+	}
+	if !strings.Contains(out, "plugin B failed to load") {
+		t.Errorf("output: got %q, want a warning about plugin B", out)
+	}
+
+	want := []struct {
+		name    string
+		enabled bool
+		status  PluginStatus
+	}{
+		{"A", true, PluginLoaded},
+		{"B", true, PluginFailed},
+		{"C", false, PluginSkipped},
+		{"D", true, PluginLoaded},
+		{"E", true, PluginSkipped},
+	}
+	plugins := vm.Plugins()
+	if len(plugins) != len(want) {
+		t.Fatalf("len(vm.Plugins()): got %d, want %d", len(plugins), len(want))
+	}
+	for i, p := range plugins {
+		w := want[i]
+		if p.Name != w.name || p.Enabled != w.enabled || p.Status != w.status {
+			t.Errorf("vm.Plugins()[%d]: got {%s %t %s}, want {%s %t %s}", i, p.Name, p.Enabled, p.Status, w.name, w.enabled, w.status)
+		}
+		if (p.Err != nil) != (w.status == PluginFailed) {
+			t.Errorf("vm.Plugins()[%d].Err: got %v", i, p.Err)
+		}
+	}
+	if got, want := plugins[0].Parameters["x"], "1"; got != want {
+		t.Errorf(`vm.Plugins()[0].Parameters["x"]: got %q, want %q`, got, want)
+	}
+	var jsErr *Error
+	if !errors.As(plugins[1].Err, &jsErr) {
+		t.Fatalf("vm.Plugins()[1].Err: got %T, want *Error", plugins[1].Err)
+	}
+	if got, want := jsErr.Type, "TypeError"; got != want {
+		t.Errorf("vm.Plugins()[1].Err.Type: got %q, want %q", got, want)
+	}
+}
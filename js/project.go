@@ -0,0 +1,127 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	indexHTMLFile   = "index.html"
+	packageJSONFile = "package.json"
+)
+
+// mainInPackageJSON returns the path of the HTML file specified as 'main' in nw.js's package.json.
+// The returned path is relative to the directory of package.json and slash-separated.
+// If main is empty or not an HTML file, mainInPackageJSON returns an empty string.
+func mainInPackageJSON(b []byte) (string, error) {
+	var p struct {
+		Main string `json:"main"`
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return "", err
+	}
+	main := p.Main
+	if i := strings.IndexAny(main, "?#"); 0 <= i {
+		main = main[:i]
+	}
+	if !strings.HasSuffix(main, ".html") {
+		return "", nil
+	}
+	return path.Clean(main), nil
+}
+
+func isFileInFS(fsys fs.FS, name string) bool {
+	fi, err := fs.Stat(fsys, name)
+	if err != nil {
+		return false
+	}
+	return !fi.IsDir()
+}
+
+var errGameNotFound = fmt.Errorf("neither %s, %s's main nor www/%s exists", indexHTMLFile, packageJSONFile, indexHTMLFile)
+
+// projectSubDir returns the slash-separated directory in fsys including index.html.
+// If the game is not found, projectSubDir returns errGameNotFound.
+func projectSubDir(fsys fs.FS) (string, error) {
+	if isFileInFS(fsys, indexHTMLFile) {
+		return ".", nil
+	}
+	b, err := fs.ReadFile(fsys, packageJSONFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err == nil {
+		main, err := mainInPackageJSON(b)
+		if err != nil {
+			return "", fmt.Errorf("js: %s: %v", packageJSONFile, err)
+		}
+		if main != "" && isFileInFS(fsys, main) {
+			return path.Dir(main), nil
+		}
+	}
+	// Some deployed games have package.json whose main is not an HTML file, and the game is in www.
+	if isFileInFS(fsys, "www/"+indexHTMLFile) {
+		return "www", nil
+	}
+	return "", errGameNotFound
+}
+
+// ProjectDir returns the project directory of the game at path.
+//
+// path can be a project directory, a file in a project directory like Game.rpgproject or index.html,
+// or a deployed game directory whose package.json points to www/index.html.
+// The returned directory includes index.html, or package.json pointing to index.html in a sub directory,
+// as the filesystem that ProjectFS accepts.
+func ProjectDir(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	dir := path
+	if !fi.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	if _, err := projectSubDir(os.DirFS(dir)); err == errGameNotFound {
+		return "", fmt.Errorf("js: game is not found at %s: %v", path, err)
+	} else if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// ProjectFS returns the sub filesystem of fsys including index.html at its root.
+//
+// fsys can be a project directory or a deployed game directory whose package.json points to www/index.html.
+func ProjectFS(fsys fs.FS) (fs.FS, error) {
+	sub, err := projectSubDir(fsys)
+	if err == errGameNotFound {
+		return nil, fmt.Errorf("js: game is not found: %v", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sub == "." {
+		return fsys, nil
+	}
+	return fs.Sub(fsys, sub)
+}
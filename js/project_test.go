@@ -0,0 +1,168 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// projectTestCases are projects and where their index.html is.
+// game is the content of the game's index.html, or empty if the game is not found.
+var projectTestCases = []struct {
+	name  string
+	files map[string]string
+	game  string
+}{
+	{
+		name: "root",
+		files: map[string]string{
+			"index.html":       "root",
+			"Game.rpgproject":  "",
+			"www/index.html":   "www",
+			"package.json":     `{"main":"www/index.html"}`,
+			"data/System.json": "{}",
+		},
+		game: "root",
+	},
+	{
+		name: "www",
+		files: map[string]string{
+			"www/index.html": "www",
+		},
+		game: "www",
+	},
+	{
+		name: "main",
+		files: map[string]string{
+			"package.json":    `{"main":"game/index.html?debug#top"}`,
+			"game/index.html": "game",
+			"www/index.html":  "www",
+		},
+		game: "game",
+	},
+	{
+		name: "no main",
+		files: map[string]string{
+			"package.json":   `{"name":"game"}`,
+			"www/index.html": "www",
+		},
+		game: "www",
+	},
+	{
+		name: "main is not HTML",
+		files: map[string]string{
+			"package.json":   `{"main":"main.js"}`,
+			"www/index.html": "www",
+		},
+		game: "www",
+	},
+	{
+		name: "main doesn't exist",
+		files: map[string]string{
+			"package.json":   `{"main":"game/index.html"}`,
+			"www/index.html": "www",
+		},
+		game: "www",
+	},
+	{
+		name: "no index.html",
+		files: map[string]string{
+			"package.json":     `{"main":"www/index.html"}`,
+			"www/js/main.js":   "",
+			"data/System.json": "{}",
+		},
+	},
+}
+
+func TestProjectFS(t *testing.T) {
+	for _, tc := range projectTestCases {
+		fsys := fstest.MapFS{}
+		for name, content := range tc.files {
+			fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		}
+		game, err := ProjectFS(fsys)
+		if tc.game == "" {
+			if err == nil {
+				t.Errorf("%s: ProjectFS must fail", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ProjectFS: %v", tc.name, err)
+			continue
+		}
+		b, err := fs.ReadFile(game, indexHTMLFile)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if string(b) != tc.game {
+			t.Errorf("%s: got %q, want %q", tc.name, b, tc.game)
+		}
+	}
+}
+
+func TestProjectDir(t *testing.T) {
+	for _, tc := range projectTestCases {
+		dir := t.TempDir()
+		for name, content := range tc.files {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := ProjectDir(dir)
+		if tc.game == "" {
+			if err == nil {
+				t.Errorf("%s: ProjectDir must fail", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ProjectDir: %v", tc.name, err)
+			continue
+		}
+		if got != dir {
+			t.Errorf("%s: got %s, want %s", tc.name, got, dir)
+		}
+	}
+
+	// A file in the project directory is also accepted.
+	dir := t.TempDir()
+	for _, name := range []string{indexHTMLFile, "Game.rpgproject"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ProjectDir(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: ProjectDir: %v", name, err)
+			continue
+		}
+		if got != dir {
+			t.Errorf("%s: got %s, want %s", name, got, dir)
+		}
+	}
+
+	if _, err := ProjectDir(filepath.Join(dir, "nothing")); err == nil {
+		t.Errorf("ProjectDir of a missing path must fail")
+	}
+}
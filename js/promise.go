@@ -0,0 +1,265 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+// promiseSrc implements Promise as specified in ECMAScript 2015 since Duktape doesn't have it.
+// Reactions are run as microtasks, which are processed after each task like a script, an onload callback,
+// a timer, an animation frame or a key event.
+// As browsers do, an exception thrown by a microtask is reported and the other microtasks still run.
+const promiseSrc = `
+var _gophermv_microtasks = [];
+var _gophermv_unhandledRejections = [];
+
+function _gophermv_enqueueMicrotask(f) {
+  _gophermv_microtasks.push(f);
+}
+
+function _gophermv_processMicrotasks() {
+  var processed = false;
+  while (_gophermv_microtasks.length) {
+    var tasks = _gophermv_microtasks;
+    _gophermv_microtasks = [];
+    for (var i = 0; i < tasks.length; i++) {
+      try {
+        tasks[i]();
+      } catch (e) {
+        _gophermv_reportError(e);
+      }
+    }
+    processed = true;
+  }
+  var rejections = _gophermv_unhandledRejections;
+  _gophermv_unhandledRejections = [];
+  for (var i = 0; i < rejections.length; i++) {
+    var p = rejections[i];
+    if (p._handled) {
+      continue;
+    }
+    var reason = p._value;
+    console.error('Uncaught (in promise)', (reason && reason.stack) || reason);
+  }
+  return processed;
+}
+
+var queueMicrotask = function(f) {
+  if (typeof f !== 'function') {
+    throw new TypeError('queueMicrotask: argument is not a function');
+  }
+  _gophermv_enqueueMicrotask(f);
+};
+
+var Promise = (function() {
+  var PENDING = 0;
+  var FULFILLED = 1;
+  var REJECTED = 2;
+
+  function Promise(executor) {
+    if (!(this instanceof Promise)) {
+      throw new TypeError('Promise: must be called with new');
+    }
+    if (typeof executor !== 'function') {
+      throw new TypeError('Promise: resolver is not a function');
+    }
+    this._state = PENDING;
+    this._value = undefined;
+    this._reactions = [];
+    this._handled = false;
+    var fns = createResolvingFunctions(this);
+    try {
+      executor(fns.resolve, fns.reject);
+    } catch (e) {
+      fns.reject(e);
+    }
+  }
+
+  function createResolvingFunctions(promise) {
+    var alreadyResolved = false;
+    return {
+      resolve: function(value) {
+        if (alreadyResolved) {
+          return;
+        }
+        alreadyResolved = true;
+        resolvePromise(promise, value);
+      },
+      reject: function(reason) {
+        if (alreadyResolved) {
+          return;
+        }
+        alreadyResolved = true;
+        settle(promise, REJECTED, reason);
+      },
+    };
+  }
+
+  function resolvePromise(promise, value) {
+    if (value === promise) {
+      settle(promise, REJECTED, new TypeError('Promise: chaining cycle detected'));
+      return;
+    }
+    if (value === null || (typeof value !== 'object' && typeof value !== 'function')) {
+      settle(promise, FULFILLED, value);
+      return;
+    }
+    var then;
+    try {
+      then = value.then;
+    } catch (e) {
+      settle(promise, REJECTED, e);
+      return;
+    }
+    if (typeof then !== 'function') {
+      settle(promise, FULFILLED, value);
+      return;
+    }
+    _gophermv_enqueueMicrotask(function() {
+      var fns = createResolvingFunctions(promise);
+      try {
+        then.call(value, fns.resolve, fns.reject);
+      } catch (e) {
+        fns.reject(e);
+      }
+    });
+  }
+
+  function settle(promise, state, value) {
+    promise._state = state;
+    promise._value = value;
+    var reactions = promise._reactions;
+    promise._reactions = null;
+    for (var i = 0; i < reactions.length; i++) {
+      scheduleReaction(promise, reactions[i]);
+    }
+    if (state === REJECTED && !promise._handled) {
+      _gophermv_unhandledRejections.push(promise);
+    }
+  }
+
+  function scheduleReaction(promise, reaction) {
+    _gophermv_enqueueMicrotask(function() {
+      var fulfilled = promise._state === FULFILLED;
+      var handler = fulfilled ? reaction.onFulfilled : reaction.onRejected;
+      if (typeof handler !== 'function') {
+        if (fulfilled) {
+          reaction.resolve(promise._value);
+        } else {
+          reaction.reject(promise._value);
+        }
+        return;
+      }
+      var result;
+      try {
+        result = handler(promise._value);
+      } catch (e) {
+        reaction.reject(e);
+        return;
+      }
+      reaction.resolve(result);
+    });
+  }
+
+  Promise.prototype.then = function(onFulfilled, onRejected) {
+    var reaction = {
+      onFulfilled: onFulfilled,
+      onRejected:  onRejected,
+    };
+    var promise = new Promise(function(resolve, reject) {
+      reaction.resolve = resolve;
+      reaction.reject = reject;
+    });
+    this._handled = true;
+    if (this._state === PENDING) {
+      this._reactions.push(reaction);
+    } else {
+      scheduleReaction(this, reaction);
+    }
+    return promise;
+  };
+
+  Promise.prototype['catch'] = function(onRejected) {
+    return this.then(undefined, onRejected);
+  };
+
+  Promise.prototype['finally'] = function(onFinally) {
+    if (typeof onFinally !== 'function') {
+      return this.then(onFinally, onFinally);
+    }
+    return this.then(function(value) {
+      return Promise.resolve(onFinally()).then(function() {
+        return value;
+      });
+    }, function(reason) {
+      return Promise.resolve(onFinally()).then(function() {
+        throw reason;
+      });
+    });
+  };
+
+  Promise.resolve = function(value) {
+    if (value instanceof Promise && value.constructor === Promise) {
+      return value;
+    }
+    return new Promise(function(resolve) {
+      resolve(value);
+    });
+  };
+
+  Promise.reject = function(reason) {
+    return new Promise(function(resolve, reject) {
+      reject(reason);
+    });
+  };
+
+  Promise.all = function(promises) {
+    return new Promise(function(resolve, reject) {
+      var values = [];
+      var remaining = promises.length;
+      if (remaining === 0) {
+        resolve(values);
+        return;
+      }
+      for (var i = 0; i < promises.length; i++) {
+        (function(i) {
+          Promise.resolve(promises[i]).then(function(value) {
+            values[i] = value;
+            remaining--;
+            if (remaining === 0) {
+              resolve(values);
+            }
+          }, reject);
+        })(i);
+      }
+    });
+  };
+
+  Promise.race = function(promises) {
+    return new Promise(function(resolve, reject) {
+      for (var i = 0; i < promises.length; i++) {
+        Promise.resolve(promises[i]).then(resolve, reject);
+      }
+    });
+  };
+
+  return Promise;
+})();
+`
+
+func (vm *VM) initPromise() error {
+	if err := vm.context.PevalString(promiseSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPromiseOrder(t *testing.T) {
+	src := `
+var result = [];
+setTimeout(function() { result.push('timeout'); }, 0);
+Promise.resolve().then(function() {
+  result.push('then1');
+}).then(function() {
+  result.push('then2');
+});
+Promise.reject(new Error('rejected'))['catch'](function(e) {
+  result.push('catch ' + e.message);
+});
+queueMicrotask(function() {
+  throw new Error('microtask');
+});
+queueMicrotask(function() {
+  result.push('microtask');
+});
+result.push('script');
+`
+	got, out := runTestGame(t, fstest.MapFS{}, src, 2)
+	if want := `["script","then1","catch rejected","microtask","then2","timeout"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if !strings.Contains(out, "Uncaught") || !strings.Contains(out, "microtask") {
+		t.Errorf("the error in the microtask is not reported: %q", out)
+	}
+}
+
+func TestPromiseThenable(t *testing.T) {
+	src := `
+var result = [];
+var thenable = {
+  then: function(resolve) {
+    result.push('then');
+    resolve(42);
+  },
+};
+Promise.resolve(thenable).then(function(v) {
+  result.push(v);
+});
+new Promise(function(resolve) {
+  resolve(Promise.resolve('promise'));
+}).then(function(v) {
+  result.push(v);
+});
+var broken = {
+  then: function() {
+    throw new Error('broken');
+  },
+};
+Promise.resolve(broken).then(null, function(e) {
+  result.push(e.message);
+});
+`
+	got, _ := runTestGame(t, fstest.MapFS{}, src, 1)
+	if want := `["then",42,"broken","promise"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPromiseAllRace(t *testing.T) {
+	src := `
+var result = {all: null, empty: null, rejected: null, race: null};
+var later = new Promise(function(resolve) {
+  setTimeout(function() { resolve(3); }, 0);
+});
+Promise.all([1, Promise.resolve(2), later]).then(function(v) {
+  result.all = v;
+});
+Promise.all([]).then(function(v) {
+  result.empty = v;
+});
+Promise.all([Promise.resolve(1), Promise.reject('no'), later]).then(null, function(e) {
+  result.rejected = e;
+});
+Promise.race([later, Promise.resolve('fast')]).then(function(v) {
+  result.race = v;
+});
+`
+	got, _ := runTestGame(t, fstest.MapFS{}, src, 2)
+	if want := `{"all":[1,2,3],"empty":[],"rejected":"no","race":"fast"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPromiseUnhandledRejection(t *testing.T) {
+	src := `
+var result = [];
+Promise.reject(new Error('unhandled'));
+Promise.reject(new Error('handled'))['catch'](function(e) {
+  result.push(e.message);
+});
+setTimeout(function() { result.push('timeout'); }, 0);
+`
+	got, out := runTestGame(t, fstest.MapFS{}, src, 2)
+	if want := `["handled","timeout"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if !strings.Contains(out, "Uncaught (in promise)") || !strings.Contains(out, "unhandled") {
+		t.Errorf("the unhandled rejection is not reported: %q", out)
+	}
+	if strings.Contains(out, "Error: handled") {
+		t.Errorf("the handled rejection is reported: %q", out)
+	}
+}
+
+func TestPromiseAnimationFrame(t *testing.T) {
+	src := `
+var result = [];
+requestAnimationFrame(function() {
+  result.push('frame1');
+  Promise.resolve().then(function() {
+    result.push('then');
+  });
+});
+requestAnimationFrame(function() {
+  result.push('frame2');
+});
+`
+	got, _ := runTestGame(t, fstest.MapFS{}, src, 1)
+	if want := `["frame1","then","frame2"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"image"
+	"image/color"
+)
+
+// Renderer creates images that canvas elements and the screen are drawn on.
+type Renderer interface {
+	NewImage(width, height int) (Image, error)
+	NewImageFromImage(img image.Image) (Image, error)
+}
+
+// Image is an image created by a Renderer.
+//
+// An Image can be drawn only onto an Image created by the same Renderer.
+type Image interface {
+	image.Image
+
+	Size() (width, height int)
+	Clear() error
+	ClearRect(x, y, width, height int) error
+	FillRect(x, y, width, height int, clr color.NRGBA) error
+	DrawImage(src Image, op *DrawImageOptions) error
+	ReplacePixels(pix []uint8) error
+}
+
+// CompositeMode represents Porter-Duff composition modes.
+type CompositeMode int
+
+const (
+	CompositeModeSourceOver CompositeMode = iota
+	CompositeModeClear
+	CompositeModeCopy
+	CompositeModeDestinationOver
+	CompositeModeSourceIn
+	CompositeModeDestinationIn
+	CompositeModeSourceOut
+	CompositeModeDestinationOut
+	CompositeModeSourceAtop
+	CompositeModeDestinationAtop
+	CompositeModeXor
+	CompositeModeLighter
+)
+
+// ImagePart is a pair of a source rectangle and a destination rectangle.
+type ImagePart struct {
+	SrcX0, SrcY0, SrcX1, SrcY1 int
+	DstX0, DstY0, DstX1, DstY1 int
+}
+
+// DrawImageOptions represents options to draw an image.
+type DrawImageOptions struct {
+	// ImageParts is the parts of the source image to draw.
+	// If ImageParts is nil, the whole source image is drawn at the origin.
+	ImageParts []ImagePart
+
+	// GeoM is a geometry matrix in the same order as CanvasRenderingContext2D.setTransform:
+	// a, b, c, d, e and f.
+	// If GeoM is nil, the identity matrix is used.
+	GeoM []float64
+
+	// Alpha is the alpha value multiplied to the source image.
+	Alpha float64
+
+	CompositeMode CompositeMode
+}
+
+func (op *DrawImageOptions) imageParts(src Image) []ImagePart {
+	if op.ImageParts != nil {
+		return op.ImageParts
+	}
+	w, h := src.Size()
+	return []ImagePart{{0, 0, w, h, 0, 0, w, h}}
+}
+
+func (op *DrawImageOptions) geoM() []float64 {
+	if op.GeoM != nil {
+		return op.GeoM
+	}
+	return []float64{1, 0, 0, 1, 0, 0}
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// A replay file consists of JSON values separated by newlines:
+// a replayHeader followed by KeyEvents in the order of dispatching.
+type replayHeader struct {
+	Seed int64 `json:"seed"`
+}
+
+func jsRandom(vm *VM) (int, error) {
+	vm.context.PushNumber(vm.random.Float64())
+	return 1, nil
+}
+
+// SetRandomSeed replaces Math.random with a pseudo-random generator seeded with the given value
+// so that a game behaves the same way every time.
+// Date.now is also replaced with the clock advancing 1000/60 milliseconds every frame, which performance.now
+// and timers always use.
+func (vm *VM) SetRandomSeed(seed int64) error {
+	vm.seed = seed
+	vm.random = rand.New(rand.NewSource(seed))
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_random", wrapFunc(jsRandom, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if err := vm.context.PevalString(`Math.random = _gophermv_random;`); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if err := vm.useFrameClock(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RecordInput writes the random seed and every key event dispatched to the game to w.
+// The written data can be passed to ReplayInput.
+//
+// If the random seed is not set by SetRandomSeed, a seed is chosen and recorded.
+func (vm *VM) RecordInput(w io.Writer) error {
+	if vm.random == nil {
+		if err := vm.SetRandomSeed(time.Now().UnixNano()); err != nil {
+			return err
+		}
+	}
+	vm.recorder = json.NewEncoder(w)
+	if err := vm.recorder.Encode(&replayHeader{Seed: vm.seed}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReplayInput reads data written by RecordInput
+// and dispatches the recorded key events instead of the actual keyboard state.
+//
+// ReplayInput must be called before RecordInput so that the recorded seed is the replayed one.
+func (vm *VM) ReplayInput(r io.Reader) error {
+	if vm.recorder != nil {
+		return errors.New("js: ReplayInput must be called before RecordInput")
+	}
+	d := json.NewDecoder(bufio.NewReader(r))
+	h := &replayHeader{}
+	if err := d.Decode(h); err != nil {
+		return fmt.Errorf("js: invalid replay header: %v", err)
+	}
+	events := []*KeyEvent{}
+	for {
+		e := &KeyEvent{}
+		if err := d.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("js: invalid replay event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if err := vm.SetRandomSeed(h.Seed); err != nil {
+		return err
+	}
+	vm.SetInput(NewReplayInput(events))
+	return nil
+}
+
+func (vm *VM) dispatchKeyEvent(e *KeyEvent) error {
+	if vm.recorder != nil {
+		if err := vm.recorder.Encode(e); err != nil {
+			return err
+		}
+	}
+	return vm.callEventHandlers(e.Type, e.KeyCode)
+}
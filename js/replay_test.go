@@ -0,0 +1,100 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	const seed = 42
+	events := []*KeyEvent{
+		{Frame: 1, Type: "keydown", KeyCode: 90},
+		{Frame: 3, Type: "keyup", KeyCode: 90},
+		{Frame: 3, Type: "keydown", KeyCode: 13},
+	}
+	// result has random numbers and the key events with the frames when they are dispatched.
+	src := `
+var result = {random: [Math.random(), Math.random()], keys: []};
+function frame() {
+  return Math.round(performance.now() * 60 / 1000);
+}
+document.addEventListener('keydown', function(e) {
+  result.keys.push([frame(), 'keydown', e.keyCode]);
+});
+document.addEventListener('keyup', function(e) {
+  result.keys.push([frame(), 'keyup', e.keyCode]);
+});
+`
+	const frames = 5
+
+	var buf bytes.Buffer
+	recorded, _ := runTestGameWith(t, fstest.MapFS{}, src, frames, func(vm *VM) error {
+		if err := vm.SetRandomSeed(seed); err != nil {
+			return err
+		}
+		vm.SetInput(NewReplayInput(events))
+		return vm.RecordInput(&buf)
+	})
+	if want := `[[1,"keydown",90],[3,"keyup",90],[3,"keydown",13]]`; !strings.Contains(recorded, want) {
+		t.Errorf("recorded: got %s, want the keys %s", recorded, want)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	h := &replayHeader{}
+	if err := d.Decode(h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Seed != seed {
+		t.Errorf("seed: got %d, want %d", h.Seed, seed)
+	}
+	var gotEvents []*KeyEvent
+	for {
+		e := &KeyEvent{}
+		if err := d.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		gotEvents = append(gotEvents, e)
+	}
+	if !reflect.DeepEqual(gotEvents, events) {
+		t.Errorf("events: got %v, want %v", gotEvents, events)
+	}
+
+	replayed, _ := runTestGameWith(t, fstest.MapFS{}, src, frames, func(vm *VM) error {
+		return vm.ReplayInput(bytes.NewReader(buf.Bytes()))
+	})
+	if replayed != recorded {
+		t.Errorf("replayed: got %s, want %s", replayed, recorded)
+	}
+}
+
+func TestRecordBeforeReplay(t *testing.T) {
+	vm := newTestVM(t, nil)
+	var buf bytes.Buffer
+	if err := vm.RecordInput(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.ReplayInput(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Errorf("ReplayInput after RecordInput must fail")
+	}
+}
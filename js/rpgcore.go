@@ -48,19 +48,40 @@ WebAudio.prototype._load = function(url) {
   this._loopLength = 0;
   this._onLoad();
 };
-WebAudio.prototype._startPlaying = function() {};
+WebAudio.prototype._startPlaying = function(loop, offset) {
+  _gophermv_notifyAudio('play', this._url, !!loop, offset || 0, this._volume, this._pitch, this._pan);
+};
+
+(function() {
+  var stop = WebAudio.prototype.stop;
+  WebAudio.prototype.stop = function() {
+    _gophermv_notifyAudio('stop', this._url, false, 0, this._volume, this._pitch, this._pan);
+    stop.call(this);
+  };
+})();
 
 TouchInput._setupEventHandlers = function() {
   // Do nothing
   // TODO: Set input handling
 };
 
+if (typeof Decrypter !== 'undefined') {
+  // Encrypted images are decrypted when loading images. See VM.ReadAsset.
+  Decrypter.checkImgIgnore = function(url) {
+    return true;
+  };
+}
+
 Utils.canReadGameFiles = function() {
   return true;
 };
 `
 
 func (vm *VM) overrideCoreClasses() error {
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_notifyAudio", wrapFunc(jsNotifyAudio, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
 	if err := vm.context.PevalString(coreClassesSrc); err != nil {
 		return err
 	}
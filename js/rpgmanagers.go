@@ -14,21 +14,6 @@
 
 package js
 
-import (
-	"io/ioutil"
-	"path/filepath"
-)
-
-func jsLoadJSONFile(vm *VM) (int, error) {
-	path := vm.context.GetString(0)
-	content, err := ioutil.ReadFile(filepath.Join(vm.pwd, path))
-	if err != nil {
-		return 0, err
-	}
-	vm.context.PushString(string(content))
-	return 1, nil
-}
-
 const managerClassesSrc = `
 SceneManager.run = function(sceneClass) {
   this.initialize();
@@ -49,22 +34,23 @@ SceneManager.shouldUseCanvasRenderer = function() {
   return true;
 };
 
-DataManager.loadDataFile = function(name, src) {
-  window[name] = null;
-  // TODO: Load file async
-  var path = 'data/' + src;
-  var content = _gophermv_loadJSONFile(path);
-  window[name] = JSON.parse(content);
-  DataManager.onLoad(window[name]);
-};
+(function() {
+  var setup = PluginManager.setup;
+  PluginManager.setup = function(plugins) {
+    plugins.forEach(function(plugin) {
+      _gophermv_addPlugin(plugin.name, !!plugin.status, JSON.stringify(plugin.parameters || {}));
+    });
+    setup.call(this, plugins);
+  };
+})();
 `
 
 func (vm *VM) overrideManagerClasses() error {
-	if err := vm.context.PevalString(managerClassesSrc); err != nil {
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_addPlugin", wrapFunc(jsAddPlugin, vm)); err != nil {
 		return err
 	}
 	vm.context.Pop()
-	if _, err := vm.context.PushGlobalGoFunction("_gophermv_loadJSONFile", wrapFunc(jsLoadJSONFile, vm)); err != nil {
+	if err := vm.context.PevalString(managerClassesSrc); err != nil {
 		return err
 	}
 	vm.context.Pop()
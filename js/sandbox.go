@@ -0,0 +1,155 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutOfSandbox is the error when a path leads out of the directory of a Sandbox.
+// ErrOutOfSandbox matches fs.ErrPermission with errors.Is.
+var ErrOutOfSandbox = fmt.Errorf("js: path is out of the game's directory: %w", fs.ErrPermission)
+
+// Sandbox is a filesystem of a directory on the local filesystem that scripts can't escape from.
+// Games are from untrusted sources, so paths with '..', absolute paths and symbolic links leading out of the
+// directory are rejected with ErrOutOfSandbox.
+//
+// Symbolic links can lead to the allowed directories like a directory of save data shared with other games.
+type Sandbox struct {
+	root    string
+	allowed []string
+}
+
+// NewSandbox returns a new Sandbox of the directory root.
+// allowed are the directories out of root where symbolic links in root can lead.
+func NewSandbox(root string, allowed ...string) (*Sandbox, error) {
+	r, err := realDir(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sandbox{root: r}
+	for _, dir := range allowed {
+		d, err := realDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		s.allowed = append(s.allowed, d)
+	}
+	return s, nil
+}
+
+func realDir(dir string) (string, error) {
+	d, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	d, err = filepath.EvalSymlinks(d)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(d)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("js: %s is not a directory", dir)
+	}
+	return d, nil
+}
+
+// Resolve returns the path on the local filesystem of name, which is a slash-separated path
+// relative to the directory like the names of fs.FS.
+// The file of name doesn't have to exist.
+//
+// The returned path has no symbolic links so that the checked path is the one that is used.
+func (s *Sandbox) Resolve(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: name, Err: ErrOutOfSandbox}
+	}
+	p := filepath.Join(s.root, filepath.FromSlash(name))
+	real, err := evalExistingSymlinks(p)
+	if err != nil {
+		return "", &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if !s.contains(real) {
+		return "", &fs.PathError{Op: "open", Path: name, Err: ErrOutOfSandbox}
+	}
+	return real, nil
+}
+
+// evalExistingSymlinks is like filepath.EvalSymlinks but p doesn't have to exist.
+func evalExistingSymlinks(p string) (string, error) {
+	rest := ""
+	for {
+		r, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(r, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if fi, err := os.Lstat(p); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			// A dangling symbolic link can lead to anywhere when its target is created.
+			return "", ErrOutOfSandbox
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
+
+func (s *Sandbox) contains(p string) bool {
+	for _, dir := range append([]string{s.root}, s.allowed...) {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sub implements fs.SubFS. The returned filesystem is a *Sandbox of the directory dir,
+// which symbolic links can lead out of only to the same allowed directories.
+func (s *Sandbox) Sub(dir string) (fs.FS, error) {
+	p, err := s.Resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := realDir(p)
+	if err != nil {
+		return nil, err
+	}
+	return &Sandbox{root: r, allowed: s.allowed}, nil
+}
+
+// Open implements fs.FS.
+func (s *Sandbox) Open(name string) (fs.File, error) {
+	p, err := s.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
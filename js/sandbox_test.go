@@ -0,0 +1,183 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Skipf("symbolic links are not available: %v", err)
+	}
+}
+
+// newTestSandbox returns a Sandbox of a new directory root, the directory out of root and the allowed directory.
+// root has these files:
+//
+//	a.txt
+//	sub/b.txt
+//	link -> a.txt
+//	out -> the directory out of root
+//	dangling -> a file out of root that doesn't exist
+//	save -> the allowed directory
+func newTestSandbox(t *testing.T) (s *Sandbox, root, outside, allowed string) {
+	t.Helper()
+	root, err := realDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, err = realDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed, err = realDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, "a.txt"), "a")
+	writeTestFile(t, filepath.Join(root, "sub", "b.txt"), "b")
+	writeTestFile(t, filepath.Join(outside, "secret.txt"), "secret")
+	writeTestFile(t, filepath.Join(allowed, "file1.rpgsave"), "save")
+	symlink(t, "a.txt", filepath.Join(root, "link"))
+	symlink(t, outside, filepath.Join(root, "out"))
+	symlink(t, filepath.Join(outside, "nothing"), filepath.Join(root, "dangling"))
+	symlink(t, allowed, filepath.Join(root, "save"))
+
+	s, err = NewSandbox(root, allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, root, outside, allowed
+}
+
+func TestSandboxResolve(t *testing.T) {
+	s, root, outside, allowed := newTestSandbox(t)
+
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: ".", want: root},
+		{name: "a.txt", want: filepath.Join(root, "a.txt")},
+		{name: "sub/b.txt", want: filepath.Join(root, "sub", "b.txt")},
+		{name: "new.txt", want: filepath.Join(root, "new.txt")},
+		{name: "new/dir/c.txt", want: filepath.Join(root, "new", "dir", "c.txt")},
+		{name: "link", want: filepath.Join(root, "a.txt")},
+		{name: "save", want: allowed},
+		{name: "save/file1.rpgsave", want: filepath.Join(allowed, "file1.rpgsave")},
+		{name: "save/file2.rpgsave", want: filepath.Join(allowed, "file2.rpgsave")},
+		{name: "..", want: ""},
+		{name: "../a.txt", want: ""},
+		{name: "sub/../a.txt", want: ""},
+		{name: "/etc/passwd", want: ""},
+		{name: filepath.ToSlash(filepath.Join(outside, "secret.txt")), want: ""},
+		{name: "out", want: ""},
+		{name: "out/secret.txt", want: ""},
+		{name: "out/new.txt", want: ""},
+		{name: "dangling", want: ""},
+		{name: "dangling/c.txt", want: ""},
+	}
+	for _, tc := range testCases {
+		got, err := s.Resolve(tc.name)
+		if tc.want == "" {
+			if !errors.Is(err, ErrOutOfSandbox) {
+				t.Errorf("Resolve(%q): got %q, %v, want ErrOutOfSandbox", tc.name, got, err)
+			}
+			if !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("Resolve(%q): the error %v must match fs.ErrPermission", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Resolve(%q): got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSandboxOpen(t *testing.T) {
+	s, _, _, _ := newTestSandbox(t)
+
+	testCases := []struct {
+		name string
+		want string
+		err  error
+	}{
+		{name: "a.txt", want: "a"},
+		{name: "link", want: "a"},
+		{name: "sub/b.txt", want: "b"},
+		{name: "save/file1.rpgsave", want: "save"},
+		{name: "new.txt", err: fs.ErrNotExist},
+		{name: "../a.txt", err: ErrOutOfSandbox},
+		{name: "out/secret.txt", err: ErrOutOfSandbox},
+		{name: "dangling", err: ErrOutOfSandbox},
+	}
+	for _, tc := range testCases {
+		got, err := fs.ReadFile(s, tc.name)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("ReadFile(%q): got %q, %v, want %v", tc.name, got, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ReadFile(%q): %v", tc.name, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("ReadFile(%q): got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSandboxSub(t *testing.T) {
+	s, _, _, _ := newTestSandbox(t)
+
+	sub, err := fs.Sub(s, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sub.(*Sandbox); !ok {
+		t.Errorf("Sub(%q): got %T, want *Sandbox", "sub", sub)
+	}
+	if b, err := fs.ReadFile(sub, "b.txt"); err != nil || string(b) != "b" {
+		t.Errorf("ReadFile(%q): got %q, %v, want %q", "b.txt", b, err, "b")
+	}
+	if _, err := fs.ReadFile(sub, "../a.txt"); !errors.Is(err, ErrOutOfSandbox) {
+		t.Errorf("ReadFile(%q): got %v, want ErrOutOfSandbox", "../a.txt", err)
+	}
+	if _, err := fs.Sub(s, "out"); !errors.Is(err, ErrOutOfSandbox) {
+		t.Errorf("Sub(%q): got %v, want ErrOutOfSandbox", "out", err)
+	}
+}
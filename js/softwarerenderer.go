@@ -0,0 +1,213 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+type softwareRenderer struct {
+}
+
+// NewSoftwareRenderer returns a Renderer drawing on image.RGBA without the GPU.
+//
+// The software renderer is slower than the Ebiten renderer,
+// but works on machines without displays or GPUs and is useful as a reference.
+func NewSoftwareRenderer() Renderer {
+	return &softwareRenderer{}
+}
+
+func (r *softwareRenderer) NewImage(width, height int) (Image, error) {
+	return &softwareImage{image.NewRGBA(image.Rect(0, 0, width, height))}, nil
+}
+
+func (r *softwareRenderer) NewImageFromImage(source image.Image) (Image, error) {
+	b := source.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), source, b.Min, draw.Src)
+	return &softwareImage{img}, nil
+}
+
+type softwareImage struct {
+	img *image.RGBA
+}
+
+func (i *softwareImage) Size() (int, int) {
+	b := i.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+func (i *softwareImage) Clear() error {
+	for j := range i.img.Pix {
+		i.img.Pix[j] = 0
+	}
+	return nil
+}
+
+func (i *softwareImage) ClearRect(x, y, width, height int) error {
+	r := image.Rect(x, y, x+width, y+height).Intersect(i.img.Bounds())
+	draw.Draw(i.img, r, image.Transparent, image.Point{}, draw.Src)
+	return nil
+}
+
+func (i *softwareImage) FillRect(x, y, width, height int, clr color.NRGBA) error {
+	r := image.Rect(x, y, x+width, y+height).Intersect(i.img.Bounds())
+	draw.Draw(i.img, r, image.NewUniform(clr), image.Point{}, draw.Over)
+	return nil
+}
+
+// compositeFactors returns the Porter-Duff factors for the source and the destination
+// in the range of [0, 0xff].
+func compositeFactors(mode CompositeMode, sa, da uint32) (uint32, uint32) {
+	switch mode {
+	case CompositeModeSourceOver:
+		return 0xff, 0xff - sa
+	case CompositeModeClear:
+		return 0, 0
+	case CompositeModeCopy:
+		return 0xff, 0
+	case CompositeModeDestinationOver:
+		return 0xff - da, 0xff
+	case CompositeModeSourceIn:
+		return da, 0
+	case CompositeModeDestinationIn:
+		return 0, sa
+	case CompositeModeSourceOut:
+		return 0xff - da, 0
+	case CompositeModeDestinationOut:
+		return 0, 0xff - sa
+	case CompositeModeSourceAtop:
+		return da, 0xff - sa
+	case CompositeModeDestinationAtop:
+		return 0xff - da, sa
+	case CompositeModeXor:
+		return 0xff - da, 0xff - sa
+	case CompositeModeLighter:
+		return 0xff, 0xff
+	}
+	panic("not reached")
+}
+
+func (i *softwareImage) DrawImage(src Image, op *DrawImageOptions) error {
+	s, ok := src.(*softwareImage)
+	if !ok {
+		return fmt.Errorf("js: the source image is not a software image: %T", src)
+	}
+	alpha := uint32(math.Min(math.Max(op.Alpha, 0), 1) * 0xff)
+	g := op.geoM()
+	dstBounds := i.img.Bounds()
+	srcBounds := s.img.Bounds()
+	for _, p := range op.imageParts(src) {
+		sw, sh := p.SrcX1-p.SrcX0, p.SrcY1-p.SrcY0
+		dw, dh := p.DstX1-p.DstX0, p.DstY1-p.DstY0
+		if sw == 0 || sh == 0 || dw == 0 || dh == 0 {
+			continue
+		}
+		// Combine the part's scaling and the geometry matrix:
+		//   x' = a*u + c*v + e
+		//   y' = b*u + d*v + f
+		// where (u, v) is a position on the source image.
+		scaleX := float64(dw) / float64(sw)
+		scaleY := float64(dh) / float64(sh)
+		ox := float64(p.DstX0) - float64(p.SrcX0)*scaleX
+		oy := float64(p.DstY0) - float64(p.SrcY0)*scaleY
+		a := g[0] * scaleX
+		b := g[1] * scaleX
+		c := g[2] * scaleY
+		d := g[3] * scaleY
+		e := g[0]*ox + g[2]*oy + g[4]
+		f := g[1]*ox + g[3]*oy + g[5]
+		det := a*d - b*c
+		if det == 0 {
+			continue
+		}
+
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, pt := range [][2]int{
+			{p.SrcX0, p.SrcY0},
+			{p.SrcX1, p.SrcY0},
+			{p.SrcX0, p.SrcY1},
+			{p.SrcX1, p.SrcY1},
+		} {
+			x := a*float64(pt[0]) + c*float64(pt[1]) + e
+			y := b*float64(pt[0]) + d*float64(pt[1]) + f
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+		r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+		r = r.Intersect(dstBounds)
+
+		su0, sv0 := float64(min(p.SrcX0, p.SrcX1)), float64(min(p.SrcY0, p.SrcY1))
+		su1, sv1 := float64(max(p.SrcX0, p.SrcX1)), float64(max(p.SrcY0, p.SrcY1))
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				// Sample the source at the center of the destination pixel (nearest filter).
+				px := float64(x) + 0.5 - e
+				py := float64(y) + 0.5 - f
+				u := (d*px - c*py) / det
+				v := (-b*px + a*py) / det
+				if u < su0 || su1 <= u || v < sv0 || sv1 <= v {
+					continue
+				}
+				pt := image.Pt(int(math.Floor(u)), int(math.Floor(v)))
+				if !pt.In(srcBounds) {
+					continue
+				}
+				si := s.img.PixOffset(pt.X, pt.Y)
+				sr := uint32(s.img.Pix[si]) * alpha / 0xff
+				sg := uint32(s.img.Pix[si+1]) * alpha / 0xff
+				sb := uint32(s.img.Pix[si+2]) * alpha / 0xff
+				sa := uint32(s.img.Pix[si+3]) * alpha / 0xff
+
+				di := i.img.PixOffset(x, y)
+				dst := i.img.Pix[di : di+4]
+				fs, fd := compositeFactors(op.CompositeMode, sa, uint32(dst[3]))
+				for j, sv := range []uint32{sr, sg, sb, sa} {
+					v := (sv*fs + uint32(dst[j])*fd) / 0xff
+					if 0xff < v {
+						v = 0xff
+					}
+					dst[j] = uint8(v)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (i *softwareImage) ReplacePixels(pix []uint8) error {
+	if len(pix) != len(i.img.Pix) {
+		return fmt.Errorf("js: len(pix) must be %d but %d", len(i.img.Pix), len(pix))
+	}
+	copy(i.img.Pix, pix)
+	return nil
+}
+
+func (i *softwareImage) ColorModel() color.Model {
+	return i.img.ColorModel()
+}
+
+func (i *softwareImage) Bounds() image.Rectangle {
+	return i.img.Bounds()
+}
+
+func (i *softwareImage) At(x, y int) color.Color {
+	return i.img.At(x, y)
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	green = color.RGBA{0, 0xff, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+func newTestSoftwareImage(t *testing.T, width, height int, pixels ...color.RGBA) Image {
+	t.Helper()
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, c := range pixels {
+		src.SetRGBA(i%width, i/width, c)
+	}
+	img, err := NewSoftwareRenderer().NewImageFromImage(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func checkPixel(t *testing.T, img Image, x, y int, want color.RGBA) {
+	t.Helper()
+	if got := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA); got != want {
+		t.Errorf("At(%d, %d): got %v, want %v", x, y, got, want)
+	}
+}
+
+func TestSoftwareImageDrawImageSourceRect(t *testing.T) {
+	src := newTestSoftwareImage(t, 2, 2,
+		red, green,
+		blue, white)
+	dst := newTestSoftwareImage(t, 3, 3)
+	op := &DrawImageOptions{
+		ImageParts: []ImagePart{{1, 0, 2, 2, 1, 1, 2, 3}},
+		Alpha:      1,
+	}
+	if err := dst.DrawImage(src, op); err != nil {
+		t.Fatal(err)
+	}
+	checkPixel(t, dst, 1, 1, green)
+	checkPixel(t, dst, 1, 2, white)
+	checkPixel(t, dst, 0, 1, color.RGBA{})
+	checkPixel(t, dst, 2, 2, color.RGBA{})
+}
+
+func TestSoftwareImageDrawImageScale(t *testing.T) {
+	src := newTestSoftwareImage(t, 2, 1, red, blue)
+	dst := newTestSoftwareImage(t, 4, 2)
+	op := &DrawImageOptions{
+		ImageParts: []ImagePart{{0, 0, 2, 1, 0, 0, 4, 2}},
+		Alpha:      1,
+	}
+	if err := dst.DrawImage(src, op); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 2; y++ {
+		checkPixel(t, dst, 0, y, red)
+		checkPixel(t, dst, 1, y, red)
+		checkPixel(t, dst, 2, y, blue)
+		checkPixel(t, dst, 3, y, blue)
+	}
+
+	// GeoM is applied after the parts are scaled.
+	dst = newTestSoftwareImage(t, 4, 2)
+	op = &DrawImageOptions{
+		GeoM:  []float64{2, 0, 0, 1, 0, 1},
+		Alpha: 1,
+	}
+	if err := dst.DrawImage(src, op); err != nil {
+		t.Fatal(err)
+	}
+	checkPixel(t, dst, 0, 0, color.RGBA{})
+	checkPixel(t, dst, 1, 1, red)
+	checkPixel(t, dst, 2, 1, blue)
+	checkPixel(t, dst, 3, 1, blue)
+}
+
+func TestSoftwareImageDrawImageAlpha(t *testing.T) {
+	src := newTestSoftwareImage(t, 1, 1, white)
+	dst := newTestSoftwareImage(t, 2, 1, color.RGBA{}, color.RGBA{0, 0, 0, 0xff})
+	op := &DrawImageOptions{
+		ImageParts: []ImagePart{
+			{0, 0, 1, 1, 0, 0, 1, 1},
+			{0, 0, 1, 1, 1, 0, 2, 1},
+		},
+		Alpha: 0.5,
+	}
+	if err := dst.DrawImage(src, op); err != nil {
+		t.Fatal(err)
+	}
+	// Pixels are alpha-premultiplied.
+	checkPixel(t, dst, 0, 0, color.RGBA{0x7f, 0x7f, 0x7f, 0x7f})
+	checkPixel(t, dst, 1, 0, color.RGBA{0x7f, 0x7f, 0x7f, 0xff})
+}
+
+func TestSoftwareImageDrawImageLighter(t *testing.T) {
+	src := newTestSoftwareImage(t, 1, 1, color.RGBA{0x90, 0x10, 0, 0xff})
+	dst := newTestSoftwareImage(t, 1, 1, color.RGBA{0x80, 0x20, 0, 0xff})
+	op := &DrawImageOptions{
+		Alpha:         1,
+		CompositeMode: CompositeModeLighter,
+	}
+	if err := dst.DrawImage(src, op); err != nil {
+		t.Fatal(err)
+	}
+	checkPixel(t, dst, 0, 0, color.RGBA{0xff, 0x30, 0, 0xff})
+}
+
+func TestSoftwareImageFillRect(t *testing.T) {
+	dst := newTestSoftwareImage(t, 4, 4)
+	if err := dst.FillRect(1, 1, 2, 2, color.NRGBA{0, 0xff, 0, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.FillRect(-1, 3, 2, 10, color.NRGBA{0xff, 0, 0, 0x80}); err != nil {
+		t.Fatal(err)
+	}
+	checkPixel(t, dst, 0, 0, color.RGBA{})
+	checkPixel(t, dst, 1, 1, green)
+	checkPixel(t, dst, 2, 2, green)
+	checkPixel(t, dst, 3, 3, color.RGBA{})
+	checkPixel(t, dst, 0, 3, color.RGBA{0x80, 0, 0, 0x80})
+	checkPixel(t, dst, 1, 0, color.RGBA{})
+}
+
+func TestSoftwareImageDrawText(t *testing.T) {
+	f, err := newFont(fstest.MapFS{
+		"fonts/mplus-1m-regular.ttf": {Data: goregular.TTF},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewSoftwareRenderer()
+	const size = 20
+	width, _ := f.measureText("Hello", size)
+
+	for _, tc := range []struct {
+		name  string
+		x     int
+		align align
+		minX  int
+	}{
+		{"left", 10, alignLeft, 10},
+		{"center", 60, alignCenter, 60 - width/2},
+		{"right", 110, alignRight, 110 - width},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, err := r.NewImage(120, 40)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.drawText(r, dst, "Hello", size, 0, tc.x, 30, 0, tc.align, white); err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for y := 0; y < 40; y++ {
+				for x := 0; x < 120; x++ {
+					_, _, _, a := dst.At(x, y).RGBA()
+					if a == 0 {
+						continue
+					}
+					n++
+					if x < tc.minX || tc.minX+width < x || 30 < y {
+						t.Errorf("pixel out of the text at (%d, %d)", x, y)
+					}
+				}
+			}
+			if n == 0 {
+				t.Errorf("no text is drawn")
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/gophermv/transpile"
+)
+
+// EnableTranspile makes the VM convert a script into ES5 when Duktape can't compile it,
+// so that scripts using ES2015 and later like class and arrow functions can run.
+// The converted scripts are cached in cacheDir by the hashes of the contents.
+// If cacheDir is empty, the scripts are converted every time.
+// EnableTranspile must be called before Run or RunHeadless.
+func (vm *VM) EnableTranspile(cacheDir string) {
+	vm.transpile = true
+	vm.transpileCache = cacheDir
+}
+
+func isSyntaxError(err *Error) bool {
+	return err.Type == "SyntaxError"
+}
+
+// transpiled returns src converted into ES5.
+func (vm *VM) transpiled(src string) (string, error) {
+	if vm.transpileCache == "" {
+		b, err := transpile.Transpile([]byte(src))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	// The version is included so that a new version of the converter doesn't use old results.
+	sum := sha256.Sum256([]byte(transpile.Version + "\x00" + src))
+	path := filepath.Join(vm.transpileCache, hex.EncodeToString(sum[:])+".js")
+	if b, err := os.ReadFile(path); err == nil {
+		return string(b), nil
+	}
+	b, err := transpile.Transpile([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomically(path, b); err != nil {
+		vm.warnf("caching a transpiled script failed: %v\n", err)
+	}
+	return string(b), nil
+}
+
+// writeFileAtomically writes b to path so that other processes never read a partially written file.
+func writeFileAtomically(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Value is a copy of a JavaScript value.
+//
+// Values are converted as JSON.stringify and encoding/json do: for example,
+// functions in objects are dropped and numbers become float64 in Interface.
+type Value struct {
+	json      []byte
+	undefined bool
+}
+
+// IsUndefined reports whether the value is undefined, or cannot be represented as JSON like functions.
+func (v *Value) IsUndefined() bool {
+	return v.undefined
+}
+
+// Interface returns the value as nil, bool, float64, string, []interface{} or map[string]interface{}.
+func (v *Value) Interface() interface{} {
+	if v.undefined {
+		return nil
+	}
+	var i interface{}
+	if err := json.Unmarshal(v.json, &i); err != nil {
+		panic(fmt.Sprintf("js: invalid JSON: %v", err))
+	}
+	return i
+}
+
+// Decode stores the value in the value pointed to by out like a map, a slice or a struct as json.Unmarshal does.
+func (v *Value) Decode(out interface{}) error {
+	if v.undefined {
+		return nil
+	}
+	return json.Unmarshal(v.json, out)
+}
+
+// MarshalJSON implements json.Marshaler. An undefined value is encoded as null.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	if v.undefined {
+		return []byte("null"), nil
+	}
+	return v.json, nil
+}
+
+// String returns the JSON representation of the value.
+func (v *Value) String() string {
+	if v.undefined {
+		return "undefined"
+	}
+	return string(v.json)
+}
+
+var valueType = reflect.TypeOf((*Value)(nil))
+
+// stringify returns JSON of the value at index.
+// ok is false if the value cannot be represented as JSON like undefined.
+func (vm *VM) stringify(index int) (string, bool, error) {
+	index = vm.context.NormalizeIndex(index)
+	vm.context.GetGlobalString("JSON")
+	vm.context.PushString("stringify")
+	vm.context.Dup(index)
+	if err := vm.intToError(vm.context.PcallProp(-3, 1)); err != nil {
+		vm.context.Pop2()
+		return "", false, err
+	}
+	defer vm.context.Pop2()
+	if vm.context.IsUndefined(-1) {
+		return "", false, nil
+	}
+	return vm.context.GetString(-1), true, nil
+}
+
+// valueAt returns a copy of the value at index.
+func (vm *VM) valueAt(index int) (*Value, error) {
+	j, ok, err := vm.stringify(index)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &Value{undefined: true}, nil
+	}
+	return &Value{json: []byte(j)}, nil
+}
+
+// parsePath parses a path like "$gameVariables._data[1]" into property names and indices.
+func parsePath(path string) ([]interface{}, error) {
+	keys := []interface{}{}
+	for _, s := range strings.Split(path, ".") {
+		name := s
+		if i := strings.Index(s, "["); i != -1 {
+			name = s[:i]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("js: invalid path: %s", path)
+		}
+		keys = append(keys, name)
+		s = s[len(name):]
+		for s != "" {
+			end := strings.Index(s, "]")
+			if s[0] != '[' || end == -1 {
+				return nil, fmt.Errorf("js: invalid path: %s", path)
+			}
+			n, err := strconv.Atoi(s[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("js: invalid index in path: %s", path)
+			}
+			keys = append(keys, n)
+			s = s[end+1:]
+		}
+	}
+	return keys, nil
+}
+
+// valueSrc defines the functions to access properties.
+// As getters and setters can throw, properties are accessed in protected calls of them.
+const valueSrc = `
+function _gophermv_getProp(obj, key) {
+  return obj[key];
+}
+
+function _gophermv_setProp(obj, key, value) {
+  obj[key] = value;
+}
+`
+
+func (vm *VM) initValue() error {
+	if err := vm.context.PevalString(valueSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
+
+func (vm *VM) pushKey(key interface{}) {
+	switch k := key.(type) {
+	case string:
+		vm.context.PushString(k)
+	case int:
+		vm.context.PushInt(k)
+	}
+}
+
+// pushProp replaces the object at the stack top with its property key.
+// If an error is thrown, the object is replaced with the error.
+func (vm *VM) pushProp(key interface{}, path string) error {
+	if vm.context.IsNullOrUndefined(-1) {
+		return fmt.Errorf("js: cannot read %v of %s in %s", key, vm.context.SafeToString(-1), path)
+	}
+	vm.context.GetGlobalString("_gophermv_getProp")
+	vm.context.Swap(-1, -2)
+	vm.pushKey(key)
+	return vm.intToError(vm.context.Pcall(2))
+}
+
+// Get returns a copy of the JavaScript value at path like "$gameVariables._data".
+// path consists of global variable and property names separated by dots, and array indices like [1].
+//
+// Get must not be called while the VM is running JavaScript on another goroutine.
+// It is safe to call Get in a frame handler, or before Run or RunHeadless.
+func (vm *VM) Get(path string) (*Value, error) {
+	keys, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	vm.context.PushGlobalObject()
+	for _, key := range keys {
+		if err := vm.pushProp(key, path); err != nil {
+			vm.context.Pop()
+			return nil, err
+		}
+	}
+	defer vm.context.Pop()
+	return vm.valueAt(-1)
+}
+
+// Set sets the Go value at path like "$gameSwitches._data[1]".
+// value is converted as json.Marshal does. A *Value is set as it is.
+//
+// The same restriction as Get applies to when Set can be called.
+func (vm *VM) Set(path string, value interface{}) error {
+	keys, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	vm.context.PushGlobalObject()
+	for _, key := range keys[:len(keys)-1] {
+		if err := vm.pushProp(key, path); err != nil {
+			vm.context.Pop()
+			return err
+		}
+	}
+	defer vm.context.Pop()
+	if !vm.context.IsObject(-1) {
+		return fmt.Errorf("js: cannot set a property of %s in %s", vm.context.SafeToString(-1), path)
+	}
+	vm.context.GetGlobalString("_gophermv_setProp")
+	vm.context.Dup(-2)
+	vm.pushKey(keys[len(keys)-1])
+	if err := vm.pushValue(reflect.ValueOf(value)); err != nil {
+		vm.context.Pop3()
+		return err
+	}
+	err = vm.intToError(vm.context.Pcall(3))
+	vm.context.Pop()
+	return err
+}
@@ -15,36 +15,85 @@
 package js
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"log"
+	"math/rand"
+	"path"
 	"runtime"
+	"sync"
 
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"gopkg.in/olebedev/go-duktape.v2"
 )
 
+var (
+	errTerminated = errors.New("js: terminated")
+)
+
 type VM struct {
-	pwd             string
+	fs              fs.FS
+	projectFS       fs.FS
 	context         *duktape.Context
 	scripts         []string
-	updatingFrameCh chan *ebiten.Image
+	updatingFrameCh chan struct{}
 	updatedFrameCh  chan struct{}
+	terminatedCh    chan struct{}
+	terminateOnce   sync.Once
+	evalCh          chan *evalRequest
 	lastImageID     int
+	images          map[int]Image
 	font            *font
+	renderer        Renderer
+	screen          Image
+	screenEImg      *ebiten.Image
+	screenWidth     int
+	screenHeight    int
+	windowTitle     string
+	frame           int
+	frameHandler    func(frame int, screen Image) error
+	seed            int64
+	random          *rand.Rand
+	recorder        *json.Encoder
+	input           Input
+	logger          *log.Logger
+	audioHandler    func(e *AudioEvent) error
+	encryption      *encryption
+	goError         *goError
+	lastGoErrorID   int
+	imageRequests   []*imageRequest
+	imageDecoder    *imageDecoder
+	lastImageReqID  int
+	overrides       map[string]*override
+	sources         map[string]string
+	transpile       bool
+	transpileCache  string
+	nwjsFS          *Sandbox
+	plugins         []*Plugin
+	pluginsByPath   map[string]*Plugin
 }
 
-func NewVM(pwd string) (*VM, error) {
+// NewVM returns a new VM running the game whose files are in fsys.
+// The root of fsys must include index.html. See ProjectFS to find such a filesystem in a project.
+func NewVM(fsys fs.FS) (*VM, error) {
 	vm := &VM{
-		pwd:             pwd,
+		fs:              fsys,
+		projectFS:       fsys,
 		context:         duktape.New(),
-		updatingFrameCh: make(chan *ebiten.Image),
+		updatingFrameCh: make(chan struct{}),
 		updatedFrameCh:  make(chan struct{}),
+		terminatedCh:    make(chan struct{}),
+		evalCh:          make(chan *evalRequest),
+		images:          map[int]Image{},
+		encryption:      &encryption{},
+		pluginsByPath:   map[string]*Plugin{},
+		sources:         map[string]string{},
 	}
 	var err error
-	vm.font, err = newFont(pwd)
+	vm.font, err = newFont(fsys)
 	if err != nil {
 		return nil, err
 	}
@@ -56,89 +105,107 @@ func NewVM(pwd string) (*VM, error) {
 }
 
 func (vm *VM) init() error {
-	vm.context.PevalString(`var console = {log:print,warn:print,error:print,info:print}`)
+	vm.initOverrides()
+	if err := vm.initError(); err != nil {
+		return err
+	}
+	if err := vm.initConsole(); err != nil {
+		return err
+	}
+	if err := vm.initPromise(); err != nil {
+		return err
+	}
 	if err := vm.initWeb(); err != nil {
 		return err
 	}
+	if err := vm.initXHR(); err != nil {
+		return err
+	}
+	if err := vm.initValue(); err != nil {
+		return err
+	}
+	if err := vm.initEval(); err != nil {
+		return err
+	}
 	if err := vm.initEbitenImage(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// SetProjectFS sets the filesystem of the project where package.json is read.
+// The project can be the parent of the game's directory like a deployed game with www.
+// SetProjectFS must be called before Run or RunHeadless.
+// If SetProjectFS is not called, package.json is read from the game's filesystem.
+func (vm *VM) SetProjectFS(fsys fs.FS) {
+	vm.projectFS = fsys
+}
+
+// SetRenderer sets the renderer to draw images.
+// SetRenderer must be called before Run or RunHeadless.
+// If SetRenderer is not called, the renderer by NewEbitenRenderer is used in Run and
+// the renderer by NewSoftwareRenderer is used in RunHeadless.
+func (vm *VM) SetRenderer(renderer Renderer) {
+	vm.renderer = renderer
+}
+
+// SetInput sets the source of key events.
+// If SetInput is not called, the keyboard is used in Run and no key events are dispatched in RunHeadless.
+func (vm *VM) SetInput(input Input) {
+	vm.input = input
+}
+
+// SetFrameHandler sets a function called with the composited screen at the end of every frame.
+// Frames are numbered from 0.
+//
+// The screen image is valid only during the call.
+func (vm *VM) SetFrameHandler(f func(frame int, screen Image) error) {
+	vm.frameHandler = f
+}
+
+func (vm *VM) initScreen() error {
+	if vm.renderer == nil {
+		r, err := NewEbitenRenderer()
+		if err != nil {
+			return err
+		}
+		vm.renderer = r
+	}
+	if err := vm.loadWindowSettings(); err != nil {
+		return err
+	}
+	screen, err := vm.renderer.NewImage(vm.screenWidth, vm.screenHeight)
+	if err != nil {
+		return err
+	}
+	vm.screen = screen
+	return nil
+}
+
+// Destroy releases the resources of the VM like the JavaScript context and the goroutines decoding images.
+// Destroy is also called when the VM is garbage-collected, but it is recommended to call Destroy explicitly.
 func (vm *VM) Destroy() {
 	if vm.context == nil {
 		return
 	}
 	vm.context.Destroy()
 	vm.context = nil
+	if vm.imageDecoder != nil {
+		vm.imageDecoder.close()
+		vm.imageDecoder = nil
+	}
 }
 
-var (
-	skips = map[string]struct{}{
-		// Why: Some elements are not defined.
-		filepath.Join("js", "libs", "fpsmeter.js"): struct{}{},
-	}
-)
+// fsPath converts a path specified by the game into a path for fs.FS.
+func fsPath(name string) string {
+	return path.Clean(name)
+}
 
 func (vm *VM) Enqueue(filename string) {
-	if _, ok := skips[filepath.Clean(filename)]; ok {
-		return
-	}
 	vm.scripts = append(vm.scripts, filename)
 }
 
-func (vm *VM) intToError(result int) error {
-	if result == 0 {
-		return nil
-	}
-	err := &duktape.Error{}
-	for _, key := range []string{"name", "message", "fileName", "lineNumber", "stack"} {
-		vm.context.GetPropString(-1, key)
-		switch key {
-		case "name":
-			err.Type = vm.context.SafeToString(-1)
-		case "message":
-			err.Message = vm.context.SafeToString(-1)
-		case "fileName":
-			err.FileName = vm.context.SafeToString(-1)
-		case "lineNumber":
-			if vm.context.IsNumber(-1) {
-				err.LineNumber = vm.context.GetInt(-1)
-			}
-		case "stack":
-			err.Stack = vm.context.SafeToString(-1)
-		}
-		vm.context.Pop()
-	}
-	return err
-}
-
-var (
-	keyCodes = map[ebiten.Key]int{
-		ebiten.KeyTab:      9,
-		ebiten.KeyEnter:    13,
-		ebiten.KeyShift:    16,
-		ebiten.KeyControl:  17,
-		ebiten.KeyAlt:      18,
-		ebiten.KeyEscape:   27,
-		ebiten.KeySpace:    32,
-		ebiten.KeyPageUp:   33,
-		ebiten.KeyPageDown: 34,
-		ebiten.KeyLeft:     37,
-		ebiten.KeyUp:       38,
-		ebiten.KeyRight:    39,
-		ebiten.KeyDown:     40,
-		ebiten.KeyInsert:   45,
-		ebiten.KeyQ:        81,
-		ebiten.KeyW:        87,
-		ebiten.KeyX:        88,
-		ebiten.KeyZ:        90,
-		ebiten.KeyF9:       120,
-	}
-)
-
-func (vm *VM) callEventHandlers(eventType string, key ebiten.Key) error {
+func (vm *VM) callEventHandlers(eventType string, keyCode int) error {
 	vm.context.GetGlobalString("document")
 	vm.context.GetPropString(-1, "_callHandlers")
 	// this
@@ -148,7 +215,7 @@ func (vm *VM) callEventHandlers(eventType string, key ebiten.Key) error {
 	// arg2: Event object
 	vm.context.GetGlobalString("Event")
 	vm.context.New(0)
-	vm.context.PushInt(keyCodes[key])
+	vm.context.PushInt(keyCode)
 	vm.context.PutPropString(-2, "keyCode")
 
 	if err := vm.intToError(vm.context.PcallMethod(2)); err != nil {
@@ -159,105 +226,279 @@ func (vm *VM) callEventHandlers(eventType string, key ebiten.Key) error {
 	return nil
 }
 
+// taskSources are the JavaScript functions running a pending task, in order of priority.
+// Images being loaded are completed before timers and the next frame.
+// Each source runs only the tasks queued when it started processing, so that a task queuing another task
+// doesn't block the next frame.
+var taskSources = []string{
+	"_gophermv_processOnLoadCallbacks",
+	"_gophermv_processRequests",
+	"_gophermv_processImages",
+	"_gophermv_processTimers",
+}
+
 func (vm *VM) loop() error {
-	keyStates := map[ebiten.Key]int{}
 	for {
 		// vm.context.Gc(0)
+		// The tasks might never drain, e.g. when a handler of a request sends another request,
+		// so termination is checked here as well as while waiting for a frame.
+		select {
+		case <-vm.terminatedCh:
+			return errTerminated
+		default:
+		}
 		if 0 < len(vm.scripts) {
-			if err := vm.exec(vm.scripts[0]); err != nil {
+			if err := vm.execScript(vm.scripts[0]); err != nil {
 				return err
 			}
 			vm.scripts = vm.scripts[1:]
+			if err := vm.processMicrotasks(); err != nil {
+				return err
+			}
 			continue
 		}
-
-		vm.context.GetGlobalString("_gophermv_processOnLoadCallbacks")
-		if err := vm.intToError(vm.context.Pcall(0)); err != nil {
+		processed, err := vm.processTask()
+		if err != nil {
 			return err
 		}
-		processed := vm.context.GetBoolean(-1)
-		vm.context.Pop()
 		if processed {
 			continue
 		}
-		if err := vm.update(keyStates); err != nil {
+		if err := vm.update(); err != nil {
 			return err
 		}
 	}
 }
 
-func (vm *VM) update(keyStates map[ebiten.Key]int) error {
-	screen := <-vm.updatingFrameCh
+// processTask runs a pending task of the first source in taskSources that has one, and then the microtasks.
+// processTask returns whether a task was run.
+func (vm *VM) processTask() (bool, error) {
+	for _, name := range taskSources {
+		processed, err := vm.processCallbacks(name)
+		if err != nil {
+			return false, err
+		}
+		if !processed {
+			continue
+		}
+		if err := vm.processMicrotasks(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// processCallbacks calls the JavaScript function name, which runs a pending callback and returns a boolean
+// whether a callback was run.
+func (vm *VM) processCallbacks(name string) (bool, error) {
+	vm.context.GetGlobalString(name)
+	if err := vm.intToError(vm.context.Pcall(0)); err != nil {
+		return false, err
+	}
+	processed := vm.context.GetBoolean(-1)
+	vm.context.Pop()
+	return processed, nil
+}
+
+// processMicrotasks runs all the pending microtasks like Promise reactions.
+func (vm *VM) processMicrotasks() error {
+	_, err := vm.processCallbacks("_gophermv_processMicrotasks")
+	return err
+}
+
+// waitForFrame waits for the next frame. Requests by Eval are processed while waiting.
+func (vm *VM) waitForFrame() error {
+	for {
+		select {
+		case <-vm.updatingFrameCh:
+			return nil
+		case r := <-vm.evalCh:
+			json, err := vm.eval(r.src)
+			r.resultCh <- evalResult{
+				json: json,
+				err:  err,
+			}
+		case <-vm.terminatedCh:
+			return errTerminated
+		}
+	}
+}
+
+func (vm *VM) update() error {
+	if err := vm.waitForFrame(); err != nil {
+		return err
+	}
 	defer func() {
 		vm.updatedFrameCh <- struct{}{}
 	}()
 
-	for key := range keyCodes {
-		if ebiten.IsKeyPressed(key) {
-			if keyStates[key] == 0 {
-				if err := vm.callEventHandlers("keydown", key); err != nil {
-					return err
-				}
+	if vm.input != nil {
+		for _, e := range vm.input.KeyEvents(vm.frame) {
+			if err := vm.dispatchKeyEvent(e); err != nil {
+				return err
 			}
-			keyStates[key]++
-		} else {
-			if keyStates[key] != 0 {
-				if err := vm.callEventHandlers("keyup", key); err != nil {
-					return err
-				}
+			if err := vm.processMicrotasks(); err != nil {
+				return err
 			}
-			keyStates[key] = 0
 		}
 	}
 
-	vm.context.GetGlobalString("_gophermv_processAnimationFrames")
-	if err := vm.intToError(vm.context.Pcall(0)); err != nil {
+	// The microtasks are run after each callback in _gophermv_processAnimationFrames.
+	if _, err := vm.processCallbacks("_gophermv_processAnimationFrames"); err != nil {
 		return err
 	}
-	vm.context.GetBoolean(-1)
-	vm.context.Pop()
-	if err := vm.updateScreen(screen); err != nil {
+	if err := vm.updateScreenSize(); err != nil {
 		return err
 	}
-	return nil
-}
-
-func detailedError(err error) error {
-	derr, ok := err.(*duktape.Error)
-	if !ok {
+	if err := vm.updateScreen(); err != nil {
 		return err
 	}
-	return fmt.Errorf("%s", derr.Stack)
+	if vm.frameHandler != nil {
+		if err := vm.frameHandler(vm.frame, vm.screen); err != nil {
+			return err
+		}
+	}
+	vm.frame++
+	return nil
 }
 
 func (vm *VM) Run() error {
-	vmError := make(chan error)
+	if err := vm.initScreen(); err != nil {
+		return err
+	}
+	if vm.input == nil {
+		vm.input = NewKeyboardInput()
+	}
+	vmError := make(chan error, 1)
+	loopDone := make(chan struct{})
 	gameStarted := make(chan struct{})
 	// TODO: Do we really have to have a goroutine?
 	go func() {
-		<-gameStarted
-		vmError <- vm.loop()
+		defer close(loopDone)
+		select {
+		case <-gameStarted:
+		case <-vm.terminatedCh:
+			return
+		}
+		err := vm.loop()
+		vm.terminate()
+		vmError <- err
 	}()
+	windowWidth, windowHeight := vm.screenWidth, vm.screenHeight
 	update := func(screen *ebiten.Image) error {
 		select {
 		case gameStarted <- struct{}{}:
 			close(gameStarted)
 			gameStarted = nil
-		case vm.updatingFrameCh <- screen:
+		case vm.updatingFrameCh <- struct{}{}:
 			<-vm.updatedFrameCh
 		case err := <-vmError:
 			return err
 		}
+		if w, h := vm.screen.Size(); w != windowWidth || h != windowHeight {
+			ebiten.SetScreenSize(w, h)
+			windowWidth, windowHeight = w, h
+		}
+		if err := vm.drawScreen(screen); err != nil {
+			return err
+		}
+		msg := fmt.Sprintf("%0.2f\n", ebiten.CurrentFPS())
+		if err := ebitenutil.DebugPrint(screen, msg); err != nil {
+			return err
+		}
 		return nil
 	}
-	// TODO: Fix the title
-	if err := ebiten.Run(update, 816, 624, 1, "test"); err != nil {
-		return detailedError(err)
+	err := ebiten.Run(update, windowWidth, windowHeight, 1, vm.windowTitle)
+	// Stop the loop when the window is closed, and wait for it not to use the VM any more.
+	vm.terminate()
+	<-loopDone
+	// errTerminated means that the game quit by itself.
+	if err != nil && err != errTerminated {
+		return vm.toError(err)
+	}
+	return nil
+}
+
+// RunHeadless runs the game for the given number of frames without opening a window.
+// Each frame is rendered to an offscreen image instead of the screen.
+//
+// As there is no keyboard without a window, no key events are dispatched unless SetInput is called.
+// Frames are run as fast as possible, so Date.now returns the time by the frame count as performance.now does.
+func (vm *VM) RunHeadless(frames int) error {
+	if err := vm.useFrameClock(); err != nil {
+		return err
+	}
+	if vm.renderer == nil {
+		// Ebiten images can't be created without ebiten.Run, which needs a display and a GPU.
+		vm.renderer = NewSoftwareRenderer()
+	}
+	if err := vm.initScreen(); err != nil {
+		return err
+	}
+	vmError := make(chan error)
+	go func() {
+		err := vm.loop()
+		vm.terminate()
+		vmError <- err
+	}()
+	for i := 0; i < frames; i++ {
+		select {
+		case vm.updatingFrameCh <- struct{}{}:
+			<-vm.updatedFrameCh
+		case err := <-vmError:
+			if err == errTerminated {
+				// The game quit by itself.
+				return nil
+			}
+			return vm.toError(err)
+		}
+	}
+	vm.terminate()
+	if err := <-vmError; err != errTerminated {
+		return vm.toError(err)
 	}
 	return nil
 }
 
-func (vm *VM) updateScreen(screen *ebiten.Image) error {
+// terminate stops the loop. Eval returns an error after terminate is called.
+func (vm *VM) terminate() {
+	vm.terminateOnce.Do(func() {
+		close(vm.terminatedCh)
+	})
+}
+
+// drawScreen draws the offscreen image onto the Ebiten screen.
+func (vm *VM) drawScreen(screen *ebiten.Image) error {
+	switch s := vm.screen.(type) {
+	case *ebitenImage:
+		return screen.DrawImage(s.img, &ebiten.DrawImageOptions{})
+	case *softwareImage:
+		w, h := s.Size()
+		if vm.screenEImg != nil {
+			if ew, eh := vm.screenEImg.Size(); ew != w || eh != h {
+				vm.screenEImg = nil
+			}
+		}
+		if vm.screenEImg == nil {
+			img, err := ebiten.NewImage(w, h, ebiten.FilterNearest)
+			if err != nil {
+				return err
+			}
+			vm.screenEImg = img
+		}
+		if err := vm.screenEImg.ReplacePixels(s.img.Pix); err != nil {
+			return err
+		}
+		return screen.DrawImage(vm.screenEImg, &ebiten.DrawImageOptions{})
+	}
+	return fmt.Errorf("js: not supported screen image: %T", vm.screen)
+}
+
+func (vm *VM) updateScreen() error {
+	if err := vm.screen.Clear(); err != nil {
+		return err
+	}
 	if err := vm.context.PevalString("document.body._canvasEbitenImages()"); err != nil {
 		return err
 	}
@@ -265,54 +506,85 @@ func (vm *VM) updateScreen(screen *ebiten.Image) error {
 	for i := 0; i < n; i++ {
 		vm.context.GetPropIndex(-1, uint(i))
 		img := vm.getEbitenImage(-1)
-		if err := screen.DrawImage(img, &ebiten.DrawImageOptions{}); err != nil {
+		op := &DrawImageOptions{
+			Alpha: 1,
+		}
+		if err := vm.screen.DrawImage(img, op); err != nil {
 			return err
 		}
 		vm.context.Pop()
 	}
 	vm.context.Pop()
-	msg := fmt.Sprintf("%0.2f\n", ebiten.CurrentFPS())
-	if err := ebitenutil.DebugPrint(screen, msg); err != nil {
-		return err
-	}
 	return nil
 }
 
 func (vm *VM) exec(filename string) error {
-	srcb, err := ioutil.ReadFile(filepath.Join(vm.pwd, filename))
-	if err != nil {
-		return err
-	}
-	src := string(srcb)
-	vm.context.PushString(filename)
-	if err := vm.context.PcompileStringFilename(0, src); err != nil {
-		return err
+	o, ok := vm.overrides[fsPath(filename)]
+	if !ok {
+		o = &override{}
 	}
-	if err := vm.intToError(vm.context.Pcall(0)); err != nil {
-		return err
+	for _, f := range o.before {
+		if err := f(vm); err != nil {
+			return err
+		}
 	}
-	if filepath.Clean(filename) == filepath.Join("js", "rpg_core.js") {
-		if err := vm.overrideCoreClasses(); err != nil {
+	if !o.skip {
+		src, err := fs.ReadFile(vm.fs, fsPath(filename))
+		if err != nil {
+			return err
+		}
+		if err := vm.execSource(filename, string(src)); err != nil {
 			return err
 		}
 	}
-	if filepath.Clean(filename) == filepath.Join("js", "rpg_managers.js") {
-		if err := vm.overrideManagerClasses(); err != nil {
+	for _, f := range o.after {
+		if err := f(vm); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// execSource executes the JavaScript src. filename is used as the file name in errors.
+// The stack is left unchanged even if an error occurs.
+func (vm *VM) execSource(filename string, src string) error {
+	vm.context.PushString(filename)
+	if vm.context.PcompileStringFilename(0, src) != nil {
+		err := vm.stackError()
+		vm.context.Pop()
+		if !vm.transpile || !isSyntaxError(err) {
+			return err
+		}
+		es5, terr := vm.transpiled(src)
+		if terr != nil {
+			return fmt.Errorf("js: %s: %v (%v)", filename, err, terr)
+		}
+		// Errors show the lines of the converted script since the line numbers are of it.
+		vm.sources[filename] = es5
+		vm.context.PushString(filename)
+		if vm.context.PcompileStringFilename(0, es5) != nil {
+			err := vm.stackError()
+			vm.context.Pop()
+			return err
+		}
+	}
+	err := vm.intToError(vm.context.Pcall(0))
+	vm.context.Pop()
+	return err
+}
+
 type Func func(vm *VM) (int, error)
 
 func wrapFunc(f Func, vm *VM) func(*duktape.Context) int {
 	return func(*duktape.Context) int {
 		r, err := f(vm)
 		if err != nil {
-			// TODO: How can we handle the error message?
-			fmt.Fprintf(os.Stderr, "%s", err.Error())
-			vm.context.PushErrorObjectVa(duktape.ErrError, "%s", err.Error())
+			// The error is thrown with the message and the cause. See errorSrc.
+			vm.lastGoErrorID++
+			vm.goError = &goError{
+				id:  vm.lastGoErrorID,
+				err: err,
+			}
 			return duktape.ErrRetError
 		}
 		return r
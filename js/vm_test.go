@@ -0,0 +1,46 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// newTestFS returns a copy of files with index.html and the font that NewVM needs.
+// files is not modified.
+func newTestFS(files fstest.MapFS) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"index.html":                 {Data: []byte("<html></html>")},
+		"fonts/mplus-1m-regular.ttf": {Data: goregular.TTF},
+	}
+	for name, f := range files {
+		fsys[name] = f
+	}
+	return fsys
+}
+
+// newTestVM returns a new VM of a game with files. See newTestFS.
+func newTestVM(t *testing.T, files fstest.MapFS) *VM {
+	t.Helper()
+	vm, err := NewVM(newTestFS(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(vm.Destroy)
+	return vm
+}
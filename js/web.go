@@ -21,6 +21,27 @@ func jsAppendScript(vm *VM) (int, error) {
 	return 0, nil
 }
 
+// frameDuration is the duration of one frame in milliseconds.
+const frameDuration = 1000.0 / 60.0
+
+// jsNow returns the time in milliseconds by the frame clock, which advances frameDuration every frame.
+// Timers and performance.now use this so that they are driven by frames both in Run and RunHeadless.
+func jsNow(vm *VM) (int, error) {
+	vm.context.PushNumber(float64(vm.frame) * frameDuration)
+	return 1, nil
+}
+
+// useFrameClock replaces Date.now with the frame clock, which performance.now already uses.
+// SceneManager decides how many times a scene is updated in a frame by them,
+// so a game doesn't depend on the speed of the machine with the frame clock.
+func (vm *VM) useFrameClock() error {
+	if err := vm.context.PevalString(`Date.now = _gophermv_now;`); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
+
 const webSrc = `
 function Window() {
 }
@@ -71,10 +92,68 @@ Window.prototype.requestAnimationFrame = function(func) {
   _gophermv_requestAnimationFrame(func);
 };
 
+Window.prototype.setTimeout = function(func, delay) {
+  return _gophermv_addTimer(func, delay, Array.prototype.slice.call(arguments, 2), false);
+};
+
+Window.prototype.setInterval = function(func, delay) {
+  return _gophermv_addTimer(func, delay, Array.prototype.slice.call(arguments, 2), true);
+};
+
+Window.prototype.clearTimeout = function(id) {
+  _gophermv_removeTimer(id);
+};
+
+Window.prototype.clearInterval = function(id) {
+  _gophermv_removeTimer(id);
+};
+
 Window.prototype.addEventListener = function() {
   // TODO: Implement this
 };
 
+function EventTarget() {
+}
+
+EventTarget.prototype.addEventListener = function(type, func) {
+  if (!this._listeners) {
+    this._listeners = {};
+  }
+  if (this._listeners[type] === undefined) {
+    this._listeners[type] = [];
+  }
+  this._listeners[type].push(func);
+};
+
+EventTarget.prototype.removeEventListener = function(type, func) {
+  if (!this._listeners || this._listeners[type] === undefined) {
+    return;
+  }
+  var listeners = this._listeners[type];
+  var index = listeners.indexOf(func);
+  if (index !== -1) {
+    listeners.splice(index, 1);
+  }
+};
+
+// _dispatchEvent calls the 'on' + type handler and the listeners.
+EventTarget.prototype._dispatchEvent = function(type) {
+  var e = new Event(type);
+  e.type = type;
+  e.target = this;
+  var handler = this['on' + type];
+  if (typeof handler === 'function') {
+    handler.call(this, e);
+  }
+  if (!this._listeners || this._listeners[type] === undefined) {
+    return;
+  }
+  var listeners = this._listeners[type].slice(0);
+  for (var i = 0; i < listeners.length; i++) {
+    listeners[i].call(this, e);
+  }
+};
+
 function Document() {
   this.initialize.apply(this, arguments);
 }
@@ -194,34 +273,69 @@ HTMLDivElement.prototype.constructor = HTMLDivElement
 function Image() {
   this.initialize.apply(this, arguments);
 }
+Image.prototype = Object.create(EventTarget.prototype);
+Image.prototype.constructor = Image
 
 Image.prototype.initialize = function() {
   this._ebitenImage = null;
+  this._src = '';
+  this._requestID = 0;
+  this.onload = null;
+  this.onerror = null;
 };
 
 Object.defineProperty(Image.prototype, 'src', {
+  get: function() {
+    return this._src;
+  },
   set: function(value) {
-    this._ebitenImage = _gophermv_loadEbitenImage(value);
+    this._src = String(value);
+    this._ebitenImage = null;
+    this._requestID = 0;
+    if (this._src === '') {
+      return;
+    }
+    // The image is loaded asynchronously. See _gophermv_processImages.
+    this._requestID = _gophermv_requestImage(this._src);
+    _gophermv_loadingImages[this._requestID] = this;
   },
 });
 
-Object.defineProperty(Image.prototype, 'width', {
+Object.defineProperty(Image.prototype, 'complete', {
+  get: function() {
+    return this._requestID === 0;
+  },
+});
+
+Object.defineProperty(Image.prototype, 'naturalWidth', {
   get: function() {
+    if (!this._ebitenImage) {
+      return 0;
+    }
     var size = _gophermv_ebitenImageSize(this._ebitenImage);
     return size[0];
   },
 });
 
-Object.defineProperty(Image.prototype, 'height', {
+Object.defineProperty(Image.prototype, 'naturalHeight', {
   get: function() {
+    if (!this._ebitenImage) {
+      return 0;
+    }
     var size = _gophermv_ebitenImageSize(this._ebitenImage);
     return size[1];
   },
 });
 
-Object.defineProperty(Image.prototype, 'onload', {
-  set: function(f) {
-    _gophermv_appendOnLoadCallback(f);
+Object.defineProperty(Image.prototype, 'width', {
+  get: function() {
+    return this.naturalWidth;
+  },
+});
+
+Object.defineProperty(Image.prototype, 'height', {
+  get: function() {
+    return this.naturalHeight;
   },
 });
 
@@ -491,6 +605,10 @@ CanvasRenderingContext2D.prototype.drawImage = function(image) {
   var dst = this._canvas._ebitenImage;
   // TODO: What if |image| is a Canvas?
   var src = image._ebitenImage;
+  if (!src) {
+    // As browsers do, an image that is not loaded yet is not drawn.
+    return;
+  }
   var state = this._stateStack[this._stateStack.length - 1];
   var op = {
     geom:          (state['transform'] || [1, 0, 0, 1, 0, 0]),
@@ -593,6 +711,9 @@ LocalStorage.prototype.removeItem = function(key) {
     Object.defineProperty(global, name, desc);
   }
   global.window = global;
+  global.performance = {
+    now: _gophermv_now,
+  };
   global._document = new Document();
   global._localStorage = new LocalStorage();
 })(this);
@@ -613,10 +734,161 @@ function _gophermv_processOnLoadCallbacks() {
   return true;
 }
 
+var _gophermv_timers = [];
+var _gophermv_lastTimerID = 0;
+
+function _gophermv_addTimer(func, delay, args, repeat) {
+  if (typeof func !== 'function') {
+    var src = String(func);
+    func = function() {
+      (0, eval)(src);
+    };
+  }
+  delay = Number(delay) || 0;
+  if (delay < 0) {
+    delay = 0;
+  }
+  var nestingLevel = _gophermv_timerNestingLevel + 1;
+  delay = _gophermv_clampTimerDelay(delay, nestingLevel);
+  _gophermv_lastTimerID++;
+  _gophermv_timers.push({
+    id:           _gophermv_lastTimerID,
+    func:         func,
+    args:         args,
+    delay:        delay,
+    repeat:       repeat,
+    when:         _gophermv_now() + delay,
+    nestingLevel: nestingLevel,
+  });
+  return _gophermv_lastTimerID;
+}
+
+// _gophermv_timerNestingLevel is the nesting level of the running timer, or 0 if no timer is running.
+var _gophermv_timerNestingLevel = 0;
+
+// _gophermv_clampTimerDelay clamps the delay of a timer nested deeper than 5 levels to 4ms as HTML specifies,
+// so that a timer adding a timer like setTimeout(f, 0) in f doesn't block the frame.
+function _gophermv_clampTimerDelay(delay, nestingLevel) {
+  if (5 < nestingLevel && delay < 4) {
+    return 4;
+  }
+  return delay;
+}
+
+function _gophermv_removeTimer(id) {
+  for (var i = 0; i < _gophermv_timers.length; i++) {
+    if (_gophermv_timers[i].id === id) {
+      _gophermv_timers.splice(i, 1);
+      return;
+    }
+  }
+}
+
+// _gophermv_reportError reports an exception thrown by a callback and lets the game continue as browsers do.
+function _gophermv_reportError(e) {
+  console.error('Uncaught', (e && e.stack) || e);
+}
+
+// _gophermv_timersSnapshot is the time and the last timer ID when processing timers started.
+// Only the timers that were due at that time are run so that a timer adding a timer
+// like setTimeout(f, 0) doesn't block the frame.
+var _gophermv_timersSnapshot = null;
+
+// _gophermv_processTimers runs the earliest timer that was due when processing timers started and returns true.
+// If there is no such timer, this returns false.
+function _gophermv_processTimers() {
+  if (_gophermv_timersSnapshot === null) {
+    _gophermv_timersSnapshot = {
+      now:    _gophermv_now(),
+      lastID: _gophermv_lastTimerID,
+    };
+  }
+  var now = _gophermv_timersSnapshot.now;
+  var lastID = _gophermv_timersSnapshot.lastID;
+  var timer = null;
+  for (var i = 0; i < _gophermv_timers.length; i++) {
+    var t = _gophermv_timers[i];
+    if (now < t.when || lastID < t.id) {
+      continue;
+    }
+    if (timer === null || t.when < timer.when || (t.when === timer.when && t.id < timer.id)) {
+      timer = t;
+    }
+  }
+  if (timer === null) {
+    _gophermv_timersSnapshot = null;
+    return false;
+  }
+  if (timer.repeat) {
+    // Each run of an interval timer is nested in the previous run.
+    timer.nestingLevel++;
+    timer.delay = _gophermv_clampTimerDelay(timer.delay, timer.nestingLevel);
+    // An interval is rescheduled from now rather than from its previous due time,
+    // so that it doesn't run again and again to catch up after a stall.
+    timer.when = now + timer.delay;
+  } else {
+    _gophermv_removeTimer(timer.id);
+  }
+  _gophermv_timerNestingLevel = timer.nestingLevel;
+  try {
+    timer.func.apply(window, timer.args);
+  } catch (e) {
+    _gophermv_reportError(e);
+  }
+  _gophermv_timerNestingLevel = 0;
+  return true;
+}
+
+var _gophermv_loadingImages = {};
+
+// _gophermv_imagesInPass is the number of the images to complete before the next frame,
+// which were being loaded when processing images started, or null if images are not being processed.
+// An image loaded by a handler of another image is completed after the next frame.
+var _gophermv_imagesInPass = null;
+
+// _gophermv_processImages waits for the earliest image being loaded, calls its handlers and returns true.
+// If there is no image being loaded, this returns false.
+function _gophermv_processImages() {
+  if (_gophermv_imagesInPass === null) {
+    _gophermv_imagesInPass = Object.keys(_gophermv_loadingImages).length;
+  }
+  if (_gophermv_imagesInPass === 0) {
+    _gophermv_imagesInPass = null;
+    return false;
+  }
+  var result = _gophermv_waitImage();
+  if (result === null) {
+    _gophermv_imagesInPass = null;
+    return false;
+  }
+  _gophermv_imagesInPass--;
+  var id = result[0];
+  var image = _gophermv_loadingImages[id];
+  delete _gophermv_loadingImages[id];
+  if (image._requestID !== id) {
+    // src was changed while loading.
+    return true;
+  }
+  image._requestID = 0;
+  var type = 'error';
+  if (result[1] !== null) {
+    image._ebitenImage = result[1];
+    type = 'load';
+  }
+  try {
+    image._dispatchEvent(type);
+  } catch (e) {
+    _gophermv_reportError(e);
+  }
+  return true;
+}
+
 function _gophermv_requestAnimationFrame(f) {
   _gophermv_requestAnimationFrameCallbacks.push(f);
 }
 
+// _gophermv_processAnimationFrames runs the animation frame callbacks requested before the call
+// and returns whether a callback was run. As browsers do, the microtasks are run after each callback.
 function _gophermv_processAnimationFrames() {
   var n = _gophermv_requestAnimationFrameCallbacks.length;
   if (n === 0) {
@@ -625,6 +897,7 @@ function _gophermv_processAnimationFrames() {
   var callbacks = _gophermv_requestAnimationFrameCallbacks.slice(0);
   for (var i = 0; i < n; i++) {
     callbacks[i]();
+    _gophermv_processMicrotasks();
   }
   _gophermv_requestAnimationFrameCallbacks = _gophermv_requestAnimationFrameCallbacks.slice(n);
   return true;
@@ -636,6 +909,10 @@ func (vm *VM) initWeb() error {
 		return err
 	}
 	vm.context.Pop()
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_now", wrapFunc(jsNow, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
 	if err := vm.context.PevalString(webSrc); err != nil {
 		return err
 	}
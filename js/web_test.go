@@ -0,0 +1,189 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"log"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testScreenSrc adds a canvas as the screen.
+const testScreenSrc = `var screen = document.createElement('canvas');
+screen.width = 16;
+screen.height = 16;
+screen.getContext('2d');
+document.body.appendChild(screen);
+`
+
+// runTestGame runs the script src for frames and returns the value of the global variable result as JSON
+// and the console output. The screen is a canvas added before src runs.
+func runTestGame(t *testing.T, files fstest.MapFS, src string, frames int) (string, string) {
+	t.Helper()
+	return runTestGameWith(t, files, src, frames, nil)
+}
+
+// runTestGameWith is like runTestGame but calls setUp with the VM before running the game if setUp is not nil.
+func runTestGameWith(t *testing.T, files fstest.MapFS, src string, frames int, setUp func(vm *VM) error) (string, string) {
+	t.Helper()
+	files = newTestFS(files)
+	files["js/test.js"] = &fstest.MapFile{Data: []byte(testScreenSrc + src)}
+	vm := newTestVM(t, files)
+	var out bytes.Buffer
+	vm.SetLogger(log.New(&out, "", 0))
+	if setUp != nil {
+		if err := setUp(vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vm.Enqueue("js/test.js")
+	if err := vm.RunHeadless(frames); err != nil {
+		t.Fatal(err)
+	}
+	v, err := vm.Get("result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.String(), out.String()
+}
+
+func TestTimerException(t *testing.T) {
+	src := `
+var result = [];
+setTimeout(function() { result.push('a'); throw new Error('timeout'); }, 0);
+setTimeout(function() { result.push('b'); }, 0);
+var n = 0;
+var id = setInterval(function() {
+  result.push('i' + n);
+  n++;
+  if (n === 3) {
+    clearInterval(id);
+  }
+  throw new Error('interval');
+}, 0);
+`
+	got, out := runTestGame(t, fstest.MapFS{}, src, 10)
+	if want := `["a","b","i0","i1","i2"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	for _, msg := range []string{"timeout", "interval"} {
+		if !strings.Contains(out, "Uncaught") || !strings.Contains(out, msg) {
+			t.Errorf("the error %q is not reported: %q", msg, out)
+		}
+	}
+}
+
+func TestTimerClamp(t *testing.T) {
+	// An interval timer of 0ms runs until it is nested deeper than 5 levels,
+	// and then runs every 4ms by the frame clock, which is once a frame.
+	src := `
+var result = {times: [], clock: Date.now() === performance.now()};
+var id = setInterval(function() {
+  result.times.push(Math.floor(performance.now()));
+  if (result.times.length === 9) {
+    clearInterval(id);
+  }
+}, 0);
+`
+	got, _ := runTestGame(t, fstest.MapFS{}, src, 5)
+	if want := `{"times":[0,0,0,0,0,16,33,50,66],"clock":true}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestIntervalNoCatchUp(t *testing.T) {
+	// A frame is longer than the interval, but the interval runs only once a frame
+	// as it is rescheduled from the time when it runs.
+	src := `
+var result = [];
+var id = setInterval(function() {
+  result.push(Math.floor(performance.now()));
+  if (result.length === 3) {
+    clearInterval(id);
+  }
+}, 5);
+`
+	got, _ := runTestGame(t, fstest.MapFS{}, src, 4)
+	if want := `[16,33,50]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestImageHandlerException(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	files := fstest.MapFS{
+		"img/a.png": {Data: buf.Bytes()},
+		"img/b.png": {Data: buf.Bytes()},
+	}
+	src := `
+var result = [];
+var a = new Image();
+a.onload = function() { result.push('a'); throw new Error('onload'); };
+a.src = 'img/a.png';
+var b = new Image();
+b.onload = function() { result.push('b'); };
+b.src = 'img/b.png';
+var c = new Image();
+c.onerror = function() { result.push('c'); throw new Error('onerror'); };
+c.src = 'img/nothing.png';
+var d = new Image();
+d.onerror = function() { result.push('d'); };
+d.src = 'img/nothing.png';
+`
+	got, out := runTestGame(t, files, src, 5)
+	if want := `["a","b","c","d"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	for _, msg := range []string{"onload", "onerror", "img/nothing.png"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("the error %q is not reported: %q", msg, out)
+		}
+	}
+}
+
+func TestImageChainDoesNotBlockFrames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	files := fstest.MapFS{
+		"img/a.png": {Data: buf.Bytes()},
+	}
+	// An image loaded by onload is completed after the next frame.
+	src := `
+var result = [];
+function load() {
+  var img = new Image();
+  img.onload = function() {
+    result.push(Math.floor(performance.now()));
+    load();
+  };
+  img.src = 'img/a.png';
+}
+load();
+`
+	got, _ := runTestGame(t, files, src, 3)
+	// The image after the last frame might be completed before the game ends.
+	if got != `[0,16,33]` && got != `[0,16,33,50]` {
+		t.Errorf("got %s, want [0,16,33]", got)
+	}
+}
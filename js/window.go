@@ -0,0 +1,129 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+const (
+	defaultScreenWidth  = 816
+	defaultScreenHeight = 624
+	defaultWindowTitle  = "gophermv"
+)
+
+// packageJSON represents the window settings in nw.js's package.json.
+type packageJSON struct {
+	Window struct {
+		Title  string `json:"title"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"window"`
+}
+
+type systemJSON struct {
+	GameTitle string `json:"gameTitle"`
+}
+
+// readJSON reads the JSON file name in fsys into v. If the file doesn't exist, v is not changed.
+func readJSON(fsys fs.FS, name string, v interface{}) error {
+	b, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("js: %s: %v", name, err)
+	}
+	return nil
+}
+
+// loadWindowSettings loads the window title and the screen size from
+// package.json and data/System.json.
+// package.json is at the root of the project, which can be the parent of the game's directory like www.
+func (vm *VM) loadWindowSettings() error {
+	vm.windowTitle = defaultWindowTitle
+	vm.screenWidth = defaultScreenWidth
+	vm.screenHeight = defaultScreenHeight
+
+	p := &packageJSON{}
+	if err := readJSON(vm.projectFS, packageJSONFile, p); err != nil {
+		return err
+	}
+	if p.Window.Title != "" {
+		vm.windowTitle = p.Window.Title
+	}
+	if 0 < p.Window.Width && 0 < p.Window.Height {
+		vm.screenWidth = p.Window.Width
+		vm.screenHeight = p.Window.Height
+	}
+
+	// Scene_Boot sets document.title to the game title.
+	s := &systemJSON{}
+	if err := readJSON(vm.fs, "data/System.json", s); err != nil {
+		return err
+	}
+	if s.GameTitle != "" {
+		vm.windowTitle = s.GameTitle
+	}
+	return nil
+}
+
+// graphicsSize returns the size of Graphics in rpg_core.js.
+// graphicsSize returns false when Graphics is not initialized yet.
+func (vm *VM) graphicsSize() (int, int, bool) {
+	vm.context.GetGlobalString("Graphics")
+	if !vm.context.IsObject(-1) {
+		vm.context.Pop()
+		return 0, 0, false
+	}
+	size := []int{0, 0}
+	for i, key := range []string{"_width", "_height"} {
+		vm.context.GetPropString(-1, key)
+		if vm.context.IsNumber(-1) {
+			size[i] = vm.context.GetInt(-1)
+		}
+		vm.context.Pop()
+	}
+	vm.context.Pop()
+	if size[0] <= 0 || size[1] <= 0 {
+		return 0, 0, false
+	}
+	return size[0], size[1], true
+}
+
+// updateScreenSize resizes the screen when Graphics is resized by e.g. resolution-changing plugins.
+func (vm *VM) updateScreenSize() error {
+	w, h, ok := vm.graphicsSize()
+	if !ok {
+		return nil
+	}
+	if w == vm.screenWidth && h == vm.screenHeight {
+		return nil
+	}
+	screen, err := vm.renderer.NewImage(w, h)
+	if err != nil {
+		return err
+	}
+	vm.screen = screen
+	vm.screenWidth = w
+	vm.screenHeight = h
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// runWindowTestGame runs the game of files for frames and returns the window title and the screen sizes of every frame.
+func runWindowTestGame(t *testing.T, files fstest.MapFS, src string, frames int) (string, []string) {
+	t.Helper()
+	files = newTestFS(files)
+	files["js/test.js"] = &fstest.MapFile{Data: []byte(src)}
+	vm := newTestVM(t, files)
+	vm.Enqueue("js/test.js")
+	var sizes []string
+	vm.SetFrameHandler(func(frame int, screen Image) error {
+		w, h := screen.Size()
+		sizes = append(sizes, fmt.Sprintf("%dx%d", w, h))
+		return nil
+	})
+	if err := vm.RunHeadless(frames); err != nil {
+		t.Fatal(err)
+	}
+	return vm.windowTitle, sizes
+}
+
+func TestWindowSettings(t *testing.T) {
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+		title string
+		size  string
+	}{
+		{
+			name:  "default",
+			files: fstest.MapFS{},
+			title: "gophermv",
+			size:  "816x624",
+		},
+		{
+			name: "package.json",
+			files: fstest.MapFS{
+				"package.json": {Data: []byte(`{"window": {"title": "Package", "width": 1280, "height": 720}}`)},
+			},
+			title: "Package",
+			size:  "1280x720",
+		},
+		{
+			name: "partial size",
+			files: fstest.MapFS{
+				"package.json": {Data: []byte(`{"window": {"width": 1280}}`)},
+			},
+			title: "gophermv",
+			size:  "816x624",
+		},
+		{
+			name: "System.json",
+			files: fstest.MapFS{
+				"package.json":     {Data: []byte(`{"window": {"title": "Package"}}`)},
+				"data/System.json": {Data: []byte(`{"gameTitle": "Game"}`)},
+			},
+			title: "Game",
+			size:  "816x624",
+		},
+	}
+	for _, c := range cases {
+		title, sizes := runWindowTestGame(t, c.files, "", 1)
+		if title != c.title {
+			t.Errorf("%s: title: got %q, want %q", c.name, title, c.title)
+		}
+		if len(sizes) != 1 || sizes[0] != c.size {
+			t.Errorf("%s: sizes: got %v, want [%s]", c.name, sizes, c.size)
+		}
+	}
+}
+
+func TestWindowSettingsProjectFS(t *testing.T) {
+	project := fstest.MapFS{
+		"package.json":         {Data: []byte(`{"window": {"title": "Deployed", "width": 640, "height": 480}}`)},
+		"www/data/System.json": {Data: []byte(`{"gameTitle": ""}`)},
+	}
+	for name, f := range newTestFS(nil) {
+		project["www/"+name] = f
+	}
+	game, err := fs.Sub(project, "www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := NewVM(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Destroy()
+	vm.SetProjectFS(project)
+	if err := vm.RunHeadless(0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := vm.windowTitle, "Deployed"; got != want {
+		t.Errorf("title: got %q, want %q", got, want)
+	}
+	if w, h := vm.screen.Size(); w != 640 || h != 480 {
+		t.Errorf("size: got %dx%d, want 640x480", w, h)
+	}
+}
+
+func TestWindowSettingsInvalidJSON(t *testing.T) {
+	for _, name := range []string{"package.json", "data/System.json"} {
+		vm := newTestVM(t, fstest.MapFS{
+			name: {Data: []byte(`{`)},
+		})
+		if err := vm.RunHeadless(1); err == nil {
+			t.Errorf("%s: RunHeadless must return an error", name)
+		}
+	}
+}
+
+func TestWindowResize(t *testing.T) {
+	// A resolution-changing plugin resizes Graphics after Graphics is initialized.
+	src := `
+var Graphics = {};
+var frames = 0;
+requestAnimationFrame(function update() {
+  frames++;
+  if (frames === 2) {
+    Graphics._width = 1280;
+    Graphics._height = 720;
+  }
+  if (frames === 3) {
+    Graphics._width = 0;
+  }
+  requestAnimationFrame(update);
+});
+`
+	files := fstest.MapFS{
+		"package.json": {Data: []byte(`{"window": {"width": 640, "height": 480}}`)},
+	}
+	_, sizes := runWindowTestGame(t, files, src, 4)
+	got := fmt.Sprint(sizes)
+	// The size is kept when Graphics's size is invalid.
+	want := "[640x480 1280x720 1280x720 1280x720]"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"errors"
+	"io/fs"
+	"unsafe"
+
+	"gopkg.in/olebedev/go-duktape.v2"
+)
+
+// pushUint8Array pushes a Uint8Array with a copy of data.
+func (vm *VM) pushUint8Array(data []byte) {
+	ptr := vm.context.PushFixedBuffer(len(data))
+	if 0 < len(data) {
+		copy(unsafe.Slice((*byte)(ptr), len(data)), data)
+	}
+	vm.context.PushBufferObject(-1, 0, len(data), duktape.BufobjUint8array)
+	vm.context.Swap(-1, -2)
+	vm.context.Pop()
+}
+
+// jsReadFile reads a file of the game as a string or a Uint8Array.
+// If the file can't be read, e.g. the file doesn't exist or is out of the sandbox, this returns null.
+func jsReadFile(vm *VM) (int, error) {
+	path := vm.context.GetString(0)
+	binary := vm.context.GetBoolean(1)
+	content, err := fs.ReadFile(vm.fs, fsPath(path))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			vm.warnf("XMLHttpRequest: %v\n", err)
+		}
+		vm.context.PushNull()
+		return 1, nil
+	}
+	if binary {
+		vm.pushUint8Array(content)
+		return 1, nil
+	}
+	vm.context.PushString(string(content))
+	return 1, nil
+}
+
+const xhrSrc = `
+function XMLHttpRequest() {
+  this.readyState = XMLHttpRequest.UNSENT;
+  this.status = 0;
+  this.statusText = '';
+  this.responseType = '';
+  this.response = null;
+  this.responseText = '';
+  this.responseURL = '';
+  this.onreadystatechange = null;
+  this.onload = null;
+  this.onerror = null;
+  this.onloadend = null;
+  this._url = null;
+}
+XMLHttpRequest.prototype = Object.create(EventTarget.prototype);
+XMLHttpRequest.prototype.constructor = XMLHttpRequest
+
+XMLHttpRequest.UNSENT = 0;
+XMLHttpRequest.OPENED = 1;
+XMLHttpRequest.HEADERS_RECEIVED = 2;
+XMLHttpRequest.LOADING = 3;
+XMLHttpRequest.DONE = 4;
+
+// _responseTypes is the values of responseType that a response can be converted into.
+XMLHttpRequest._responseTypes = ['', 'text', 'json', 'arraybuffer'];
+
+XMLHttpRequest.prototype.open = function(method, url) {
+  if (String(method).toUpperCase() !== 'GET') {
+    throw new Error('XMLHttpRequest.open: not supported method: ' + method);
+  }
+  url = String(url);
+  if (/^[a-z]+:/i.test(url)) {
+    throw new Error('XMLHttpRequest.open: not supported URL: ' + url);
+  }
+  this._url = url;
+  this.readyState = XMLHttpRequest.OPENED;
+  this._dispatchEvent('readystatechange');
+};
+
+XMLHttpRequest.prototype.overrideMimeType = function(mime) {
+  // The response is interpreted by responseType.
+};
+
+XMLHttpRequest.prototype.setRequestHeader = function(name, value) {
+  // Ignore the request headers.
+};
+
+XMLHttpRequest.prototype.getResponseHeader = function(name) {
+  return null;
+};
+
+XMLHttpRequest.prototype.getAllResponseHeaders = function() {
+  return '';
+};
+
+XMLHttpRequest.prototype.send = function() {
+  if (this.readyState !== XMLHttpRequest.OPENED) {
+    throw new Error('XMLHttpRequest.send: the request is not opened');
+  }
+  if (XMLHttpRequest._responseTypes.indexOf(this.responseType) === -1) {
+    throw new Error('XMLHttpRequest.send: not supported responseType: ' + this.responseType);
+  }
+  _gophermv_requests.push(this);
+};
+
+XMLHttpRequest.prototype.abort = function() {
+  var index = _gophermv_requests.indexOf(this);
+  if (index !== -1) {
+    _gophermv_requests.splice(index, 1);
+  }
+  this.readyState = XMLHttpRequest.UNSENT;
+};
+
+// _dispatchEventInTask dispatches the event in a task like loading the response.
+// As browsers do, an exception thrown by a handler is reported and doesn't stop the game.
+XMLHttpRequest.prototype._dispatchEventInTask = function(type) {
+  try {
+    this._dispatchEvent(type);
+  } catch (e) {
+    _gophermv_reportError(e);
+  }
+};
+
+XMLHttpRequest.prototype._setReadyState = function(readyState) {
+  this.readyState = readyState;
+  this._dispatchEventInTask('readystatechange');
+};
+
+XMLHttpRequest.prototype._complete = function() {
+  var content = null;
+  try {
+    // Remove the query and the fragment.
+    var path = decodeURIComponent(this._url.replace(/[?#].*$/, ''));
+    content = _gophermv_readFile(path, this.responseType === 'arraybuffer');
+  } catch (e) {
+    // A malformed URL is a network error.
+  }
+  this.responseURL = this._url;
+  // responseType might be changed to an unsupported value after send.
+  if (content === null || XMLHttpRequest._responseTypes.indexOf(this.responseType) === -1) {
+    // Like NW.js loading local files, a file that can't be read is an error rather than 404.
+    this._setReadyState(XMLHttpRequest.DONE);
+    this._dispatchEventInTask('error');
+    this._dispatchEventInTask('loadend');
+    return;
+  }
+  this.status = 200;
+  this.statusText = 'OK';
+  this._setReadyState(XMLHttpRequest.HEADERS_RECEIVED);
+  this._setReadyState(XMLHttpRequest.LOADING);
+  switch (this.responseType) {
+  case '':
+  case 'text':
+    this.responseText = content;
+    this.response = content;
+    break;
+  case 'json':
+    try {
+      this.response = JSON.parse(content);
+    } catch (e) {
+      this.response = null;
+    }
+    break;
+  case 'arraybuffer':
+    this.response = content.buffer;
+    break;
+  }
+  this._setReadyState(XMLHttpRequest.DONE);
+  this._dispatchEventInTask('load');
+  this._dispatchEventInTask('loadend');
+};
+
+var _gophermv_requests = [];
+
+// _gophermv_requestsInPass is the number of the requests to complete before the next frame,
+// which were sent when processing requests started, or null if requests are not being processed.
+// A request sent by a handler of another request is completed after the next frame.
+var _gophermv_requestsInPass = null;
+
+// _gophermv_processRequests completes the earliest sent request and returns true.
+// If there is no such request, this returns false.
+function _gophermv_processRequests() {
+  if (_gophermv_requestsInPass === null) {
+    _gophermv_requestsInPass = _gophermv_requests.length;
+  }
+  // A request might be aborted while processing requests.
+  if (_gophermv_requestsInPass === 0 || _gophermv_requests.length === 0) {
+    _gophermv_requestsInPass = null;
+    return false;
+  }
+  _gophermv_requestsInPass--;
+  _gophermv_requests.shift()._complete();
+  return true;
+}
+`
+
+func (vm *VM) initXHR() error {
+	if _, err := vm.context.PushGlobalGoFunction("_gophermv_readFile", wrapFunc(jsReadFile, vm)); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	if err := vm.context.PevalString(xhrSrc); err != nil {
+		return err
+	}
+	vm.context.Pop()
+	return nil
+}
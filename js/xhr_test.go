@@ -0,0 +1,183 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testXHRFiles = fstest.MapFS{
+	"data/a.json": {Data: []byte(`{"a":[1,2]}`)},
+	"data/b.bin":  {Data: []byte{0, 1, 255}},
+	"data/c.txt":  {Data: []byte("text")},
+}
+
+// testXHRSrc defines load, which sends a request of url and calls f with the request when it is loaded.
+const testXHRSrc = `
+function load(url, responseType, f) {
+  var xhr = new XMLHttpRequest();
+  xhr.open('GET', url);
+  xhr.responseType = responseType;
+  xhr.onload = function() {
+    f(xhr);
+  };
+  xhr.send();
+}
+`
+
+func TestXHRResponseType(t *testing.T) {
+	src := testXHRSrc + `
+var result = {};
+load('data/a.json', 'json', function(xhr) {
+  result.json = xhr.response;
+});
+load('data/b.bin', 'arraybuffer', function(xhr) {
+  result.arraybuffer = Array.prototype.slice.call(new Uint8Array(xhr.response));
+});
+load('data/c.txt?q=1', '', function(xhr) {
+  result.text = [xhr.response, xhr.responseText, xhr.status];
+});
+`
+	got, _ := runTestGame(t, testXHRFiles, src, 1)
+	if want := `{"json":{"a":[1,2]},"arraybuffer":[0,1,255],"text":["text","text",200]}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestXHREvents(t *testing.T) {
+	testCases := []struct {
+		url  string
+		want string
+	}{
+		{
+			url:  "data/a.json",
+			want: `["readystatechange 1","readystatechange 2","readystatechange 3","readystatechange 4","load 200","loadend"]`,
+		},
+		{
+			url:  "data/nothing.json",
+			want: `["readystatechange 1","readystatechange 4","error 0","loadend"]`,
+		},
+		{
+			url:  "data/%E0%A4%A.json",
+			want: `["readystatechange 1","readystatechange 4","error 0","loadend"]`,
+		},
+	}
+	for _, tc := range testCases {
+		src := `
+var result = [];
+var xhr = new XMLHttpRequest();
+xhr.onreadystatechange = function() {
+  result.push('readystatechange ' + xhr.readyState);
+};
+xhr.onload = function() {
+  result.push('load ' + xhr.status);
+};
+xhr.onerror = function() {
+  result.push('error ' + xhr.status);
+};
+xhr.onloadend = function() {
+  result.push('loadend');
+};
+xhr.open('GET', '` + tc.url + `');
+xhr.send();
+`
+		got, _ := runTestGame(t, testXHRFiles, src, 1)
+		if got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestXHRHandlerException(t *testing.T) {
+	src := testXHRSrc + `
+var result = [];
+load('data/a.json', 'json', function(xhr) {
+  result.push('a');
+  throw new Error('onload');
+});
+load('data/c.txt', 'text', function(xhr) {
+  result.push('c');
+});
+`
+	got, out := runTestGame(t, testXHRFiles, src, 1)
+	if want := `["a","c"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if !strings.Contains(out, "Uncaught") || !strings.Contains(out, "onload") {
+		t.Errorf("the error is not reported: %q", out)
+	}
+}
+
+func TestXHRUnsupportedResponseType(t *testing.T) {
+	src := `
+var result = [];
+var xhr = new XMLHttpRequest();
+xhr.open('GET', 'data/c.txt');
+xhr.responseType = 'blob';
+try {
+  xhr.send();
+} catch (e) {
+  result.push('send ' + e.message);
+}
+
+// responseType is changed after send.
+var xhr2 = new XMLHttpRequest();
+xhr2.onerror = function() {
+  result.push('error ' + xhr2.readyState);
+};
+xhr2.onloadend = function() {
+  result.push('loadend');
+};
+xhr2.open('GET', 'data/c.txt');
+xhr2.send();
+xhr2.responseType = 'document';
+
+var xhr3 = new XMLHttpRequest();
+xhr3.onload = function() {
+  result.push('load ' + xhr3.response);
+};
+xhr3.open('GET', 'data/c.txt');
+xhr3.send();
+`
+	got, _ := runTestGame(t, testXHRFiles, src, 1)
+	if want := `["send XMLHttpRequest.send: not supported responseType: blob","error 4","loadend","load text"]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestXHRChainDoesNotBlockFrames(t *testing.T) {
+	// A request sent by onload is completed after the next frame, so that the game doesn't stop
+	// even though the requests never end.
+	src := `
+var result = [];
+function send() {
+  var xhr = new XMLHttpRequest();
+  xhr.open('GET', 'data/c.txt');
+  xhr.onload = function() {
+    result.push(Math.floor(performance.now()));
+    send();
+  };
+  xhr.send();
+}
+send();
+`
+	got, _ := runTestGame(t, testXHRFiles, src, 3)
+	// The request after the last frame might be completed before the game ends.
+	if got != `[0,16,33]` && got != `[0,16,33,50]` {
+		t.Errorf("got %s, want [0,16,33]", got)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package player provides a player of RPG Maker MV games to embed in Go programs.
+package player
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/hajimehoshi/gophermv/js"
+)
+
+// Options represents options of a Player.
+type Options struct {
+	// Dir is the path to the game, which is interpreted in the same way as js.ProjectDir.
+	Dir string
+
+	// FS is the filesystem of the game like a zip archive or embed.FS,
+	// which is interpreted in the same way as js.ProjectFS.
+	// If FS is not nil, FS is used instead of Dir.
+	FS fs.FS
+
+	// Input is the source of key events.
+	// If Input is nil, the keyboard is used in Run and no key events are dispatched in RunHeadless.
+	Input js.Input
+
+	// FrameSink is called with the screen at the end of every frame.
+	// The screen image is valid only during the call.
+	FrameSink func(frame int, screen image.Image) error
+
+	// AudioSink is called when the game plays or stops audio.
+	AudioSink func(e *js.AudioEvent) error
+
+	// Logger is the logger for console output of the game and warnings.
+	// If Logger is nil, console output goes to stdout and warnings go to stderr.
+	Logger *log.Logger
+
+	// Renderer is the renderer to draw images.
+	// If Renderer is nil, the renderer by js.NewEbitenRenderer is used in Run and
+	// the renderer by js.NewSoftwareRenderer is used in RunHeadless.
+	Renderer js.Renderer
+
+	// Overrides is the filesystem of the rules to modify scripts. See js.VM.LoadOverrides.
+	Overrides fs.FS
+
+	// OverridesDir is the directory of the rules to modify scripts.
+	// OverridesDir is used when Overrides is nil.
+	OverridesDir string
+
+	// Transpile specifies whether scripts written in ES2015 and later are converted into ES5.
+	// See js.VM.EnableTranspile.
+	Transpile bool
+
+	// TranspileCacheDir is the directory to cache converted scripts.
+	// If TranspileCacheDir is empty, converted scripts are not cached.
+	TranspileCacheDir string
+
+	// NWJS specifies whether require, process and nw of nw.js are provided to scripts.
+	// See js.VM.EnableNWJS.
+	NWJS bool
+
+	// NWJSDir is the directory where scripts write files like save data when NWJS is true.
+	// If NWJSDir is empty, the game's directory is used when FS is nil, and writing files fails otherwise.
+	// NWJSDir must exist.
+	NWJSDir string
+
+	// AllowedDirs are the directories out of Dir and NWJSDir that symbolic links in them can lead to,
+	// like a directory of save data. Other symbolic links leading out of Dir and NWJSDir are rejected.
+	AllowedDirs []string
+}
+
+// Player plays a game.
+type Player struct {
+	vm *js.VM
+}
+
+// New returns a new Player loading the game specified by options.
+func New(options *Options) (*Player, error) {
+	fsys := options.FS
+	if fsys == nil {
+		dir, err := js.ProjectDir(options.Dir)
+		if err != nil {
+			return nil, err
+		}
+		s, err := js.NewSandbox(dir, options.AllowedDirs...)
+		if err != nil {
+			return nil, err
+		}
+		fsys = s
+	}
+	gameFS, err := js.ProjectFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var nwjsFS *js.Sandbox
+	if options.FS == nil {
+		// Sub directories of a Sandbox are also Sandboxes. See js.Sandbox.Sub.
+		s, ok := gameFS.(*js.Sandbox)
+		if !ok {
+			return nil, fmt.Errorf("player: the game's filesystem is not a sandbox: %T", gameFS)
+		}
+		nwjsFS = s
+	}
+	if options.NWJS && options.NWJSDir != "" {
+		s, err := js.NewSandbox(options.NWJSDir, options.AllowedDirs...)
+		if err != nil {
+			return nil, err
+		}
+		nwjsFS = s
+	}
+	scripts, err := js.LoadScripts(gameFS)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := js.NewVM(gameFS)
+	if err != nil {
+		return nil, err
+	}
+	vm.SetProjectFS(fsys)
+	if err := setUp(vm, options, nwjsFS); err != nil {
+		vm.Destroy()
+		return nil, err
+	}
+	for _, s := range scripts {
+		vm.Enqueue(s)
+	}
+	return &Player{
+		vm: vm,
+	}, nil
+}
+
+func setUp(vm *js.VM, options *Options, nwjsFS *js.Sandbox) error {
+	if options.Input != nil {
+		vm.SetInput(options.Input)
+	}
+	if options.FrameSink != nil {
+		sink := options.FrameSink
+		vm.SetFrameHandler(func(frame int, screen js.Image) error {
+			return sink(frame, screen)
+		})
+	}
+	if options.AudioSink != nil {
+		vm.SetAudioHandler(options.AudioSink)
+	}
+	if options.Logger != nil {
+		vm.SetLogger(options.Logger)
+	}
+	if options.Renderer != nil {
+		vm.SetRenderer(options.Renderer)
+	}
+	switch {
+	case options.Overrides != nil:
+		if err := vm.LoadOverrides(options.Overrides, "overrides"); err != nil {
+			return err
+		}
+	case options.OverridesDir != "":
+		if err := vm.LoadOverrides(os.DirFS(options.OverridesDir), options.OverridesDir); err != nil {
+			return err
+		}
+	}
+	if options.Transpile {
+		vm.EnableTranspile(options.TranspileCacheDir)
+	}
+	if options.NWJS {
+		if err := vm.EnableNWJS(nwjsFS); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run runs the game in a window until the window is closed or an error occurs.
+func (p *Player) Run() error {
+	return p.vm.Run()
+}
+
+// RunHeadless runs the game for the given number of frames without opening a window.
+func (p *Player) RunHeadless(frames int) error {
+	return p.vm.RunHeadless(frames)
+}
+
+// SetRandomSeed makes Math.random, Date.now and performance.now deterministic.
+// See js.VM.SetRandomSeed.
+func (p *Player) SetRandomSeed(seed int64) error {
+	return p.vm.SetRandomSeed(seed)
+}
+
+// RecordInput writes the random seed and the key events to w. See js.VM.RecordInput.
+func (p *Player) RecordInput(w io.Writer) error {
+	return p.vm.RecordInput(w)
+}
+
+// ReplayInput dispatches the key events read from r, which is written by RecordInput.
+// See js.VM.ReplayInput.
+func (p *Player) ReplayInput(r io.Reader) error {
+	return p.vm.ReplayInput(r)
+}
+
+// Eval evaluates the JavaScript src and returns the result as JSON.
+// Eval can be called from any goroutine while the game is running. See js.VM.Eval.
+func (p *Player) Eval(src string) (string, error) {
+	return p.vm.Eval(src)
+}
+
+// ReadAsset reads a file of the game like an audio file, decrypting it if the game is encrypted.
+func (p *Player) ReadAsset(name string) ([]byte, error) {
+	return p.vm.ReadAsset(name)
+}
+
+// Register registers the Go function fn as a global JavaScript function name.
+// Register must be called before Run or RunHeadless. See js.VM.Register for the conversion of values.
+func (p *Player) Register(name string, fn interface{}) error {
+	return p.vm.Register(name, fn)
+}
+
+// Plugins returns the plugins of the game and whether they are loaded.
+func (p *Player) Plugins() []*js.Plugin {
+	return p.vm.Plugins()
+}
+
+// Get returns a copy of the JavaScript value at path like "$gameVariables._data".
+// Get can be called in FrameSink. See js.VM.Get for details.
+func (p *Player) Get(path string) (*js.Value, error) {
+	return p.vm.Get(path)
+}
+
+// Set sets the Go value at path like "$gameSwitches._data[1]".
+// Set can be called in FrameSink. See js.VM.Set for details.
+func (p *Player) Set(path string, value interface{}) error {
+	return p.vm.Set(path, value)
+}
+
+// Close releases the resources of the player.
+func (p *Player) Close() {
+	p.vm.Destroy()
+}
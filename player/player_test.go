@@ -0,0 +1,210 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package player
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/hajimehoshi/gophermv/js"
+)
+
+// testMainSrc draws a red rectangle at the top-left corner of the screen and counts frames.
+const testMainSrc = `var screen = document.createElement('canvas');
+screen.width = 16;
+screen.height = 16;
+var context = screen.getContext('2d');
+context.fillStyle = '#ff0000';
+context.fillRect(0, 0, 8, 8);
+document.body.appendChild(screen);
+
+var result = {frames: 0, keys: []};
+document.addEventListener('keydown', function(e) {
+  result.keys.push(e.keyCode);
+});
+requestAnimationFrame(function update() {
+  result.frames++;
+  requestAnimationFrame(update);
+});
+console.log('started');
+`
+
+// newTestGame returns the files of a game running testMainSrc.
+func newTestGame() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":                 {Data: []byte(`<html><body><script src="js/main.js"></script></body></html>`)},
+		"fonts/mplus-1m-regular.ttf": {Data: goregular.TTF},
+		"js/main.js":                 {Data: []byte(testMainSrc)},
+	}
+}
+
+func newTestPlayer(t *testing.T, options *Options) *Player {
+	t.Helper()
+	if options.Renderer == nil {
+		options.Renderer = js.NewSoftwareRenderer()
+	}
+	p, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPlayerRunHeadless(t *testing.T) {
+	var out bytes.Buffer
+	var p *Player
+	var frames []int
+	var counts []int
+	options := &Options{
+		FS:     newTestGame(),
+		Logger: log.New(&out, "", 0),
+		FrameSink: func(frame int, screen image.Image) error {
+			frames = append(frames, frame)
+			if got, want := screen.Bounds(), image.Rect(0, 0, 816, 624); got != want {
+				t.Errorf("frame %d: bounds: got %v, want %v", frame, got, want)
+			}
+			red := color.NRGBA{0xff, 0, 0, 0xff}
+			if got := color.NRGBAModel.Convert(screen.At(0, 0)); got != red {
+				t.Errorf("frame %d: At(0, 0): got %v, want %v", frame, got, red)
+			}
+			if got := color.NRGBAModel.Convert(screen.At(8, 8)); got == red {
+				t.Errorf("frame %d: At(8, 8): got %v, want not %v", frame, got, red)
+			}
+			// Get and Set can be called in FrameSink.
+			v, err := p.Get("result.frames")
+			if err != nil {
+				return err
+			}
+			var n int
+			if err := v.Decode(&n); err != nil {
+				return err
+			}
+			counts = append(counts, n)
+			return p.Set("result.frames", n*10)
+		},
+	}
+	p = newTestPlayer(t, options)
+	if err := p.RunHeadless(3); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := frames, []int{0, 1, 2}; !equalInts(got, want) {
+		t.Errorf("frames: got %v, want %v", got, want)
+	}
+	// result.frames is multiplied by 10 at the end of every frame.
+	if got, want := counts, []int{1, 11, 111}; !equalInts(got, want) {
+		t.Errorf("result.frames: got %v, want %v", got, want)
+	}
+	if !strings.Contains(out.String(), "started") {
+		t.Errorf("output: got %q, want console output", out.String())
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPlayerRegisterAndInput(t *testing.T) {
+	files := newTestGame()
+	files["js/main.js"] = &fstest.MapFile{Data: []byte(testMainSrc + `result.sum = goAdd(1, 2);`)}
+	p := newTestPlayer(t, &Options{
+		FS: files,
+		Input: js.NewReplayInput([]*js.KeyEvent{
+			{Frame: 1, Type: "keydown", KeyCode: 90},
+			{Frame: 2, Type: "keydown", KeyCode: 13},
+		}),
+	})
+	if err := p.Register("goAdd", func(a, b int) int { return a + b }); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RunHeadless(3); err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.Get("result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.String(), `{"frames":3,"keys":[90,13],"sum":3}`; got != want {
+		t.Errorf("result: got %s, want %s", got, want)
+	}
+}
+
+func TestPlayerDir(t *testing.T) {
+	// A deployed game is in www.
+	dir := t.TempDir()
+	for name, f := range newTestGame() {
+		path := filepath.Join(dir, "www", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, f.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"main": "www/index.html", "window": {"width": 640, "height": 480}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var bounds image.Rectangle
+	p := newTestPlayer(t, &Options{
+		Dir: dir,
+		FrameSink: func(frame int, screen image.Image) error {
+			bounds = screen.Bounds()
+			return nil
+		},
+	})
+	if err := p.RunHeadless(1); err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(0, 0, 640, 480); bounds != want {
+		t.Errorf("bounds: got %v, want %v", bounds, want)
+	}
+	b, err := p.ReadAsset("js/main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testMainSrc {
+		t.Errorf("ReadAsset: got %q, want %q", b, testMainSrc)
+	}
+}
+
+func TestPlayerGameNotFound(t *testing.T) {
+	if _, err := New(&Options{FS: fstest.MapFS{}}); err == nil {
+		t.Error("New with an empty FS must return an error")
+	}
+	if _, err := New(&Options{Dir: t.TempDir()}); err == nil {
+		t.Error("New with an empty directory must return an error")
+	}
+	if _, err := New(&Options{Dir: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("New with a missing directory must return an error")
+	}
+}
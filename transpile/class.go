@@ -0,0 +1,167 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+import (
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// class converts a class into a function call that returns the constructor like:
+//
+//	(function(_super) {
+//	    _gophermv_inherits(C, _super);
+//	    function C() { _super.apply(this, arguments); }
+//	    _gophermv_defineProperty(C.prototype, "m", "value", function() { ... }, false);
+//	    return C;
+//	})(Base)
+func (t *transpiler) class(c *js.ClassDecl) js.IExpr {
+	name := c.Name
+	if name == nil {
+		name = t.newVar("class")
+	}
+	var super *js.Var
+	var params js.Params
+	var args js.Args
+	if c.Extends != nil {
+		super = t.newVar("super")
+		params.List = []js.BindingElement{{Binding: super}}
+		args.List = []js.Arg{{Value: t.expr(c.Extends)}}
+	}
+
+	// The class body uses this of the outer function like an arrow function.
+	f := &funcContext{arrow: true, parent: t.fn}
+	t.fn = f
+
+	var body []js.IStmt
+	if super != nil {
+		body = append(body, &js.ExprStmt{Value: call(t.helper("inherits"), name, super)})
+	}
+
+	var ctor *js.MethodDecl
+	var fields []js.IStmt
+	var elems []js.ClassElement
+	for _, e := range c.List {
+		switch {
+		case e.Method != nil && !e.Method.Static && e.Method.Name.IsIdent([]byte("constructor")):
+			ctor = e.Method
+		case e.Method == nil && e.StaticBlock == nil && !e.Field.Static:
+			fields = append(fields, t.field(&e.Field))
+		default:
+			elems = append(elems, e)
+		}
+	}
+
+	var ctorParams js.Params
+	var ctorBody js.BlockStmt
+	if ctor != nil {
+		ctorParams, ctorBody = ctor.Params, ctor.Body
+	} else if super != nil {
+		ctorBody.List = []js.IStmt{
+			&js.ExprStmt{Value: call(dot(super, "apply"), thisExpr(), argumentsVar())},
+		}
+	}
+	if len(fields) > 0 {
+		// Fields are initialized after super() is called.
+		i := 0
+		if ctor != nil && super != nil {
+			i = -1
+			for j, s := range ctorBody.List {
+				if e, ok := s.(*js.ExprStmt); ok {
+					if c, ok := e.Value.(*js.CallExpr); ok && isSuper(c.X) {
+						i = j + 1
+						break
+					}
+				}
+			}
+		} else if super != nil {
+			i = 1
+		}
+		if i < 0 {
+			t.errorf("class fields need super() at the top level of the constructor")
+		} else {
+			list := append([]js.IStmt{}, ctorBody.List[:i]...)
+			list = append(list, fields...)
+			ctorBody.List = append(list, ctorBody.List[i:]...)
+		}
+	}
+	t.function(&ctorParams, &ctorBody, &funcContext{super: super, ctor: true})
+	body = append(body, &js.FuncDecl{Name: name, Params: ctorParams, Body: ctorBody})
+
+	for _, e := range elems {
+		switch {
+		case e.StaticBlock != nil:
+			fn := &js.FuncDecl{Body: *e.StaticBlock}
+			t.function(&fn.Params, &fn.Body, &funcContext{super: super, static: true})
+			body = append(body, &js.ExprStmt{Value: call(dot(fn, "call"), name)})
+		case e.Method != nil:
+			m := e.Method
+			if m.Async || m.Generator {
+				t.errorf("async functions and generators are not supported")
+				continue
+			}
+			key := t.propKey(t.classKey(&m.Name))
+			t.function(&m.Params, &m.Body, &funcContext{super: super, static: m.Static})
+			var target js.IExpr = name
+			if !m.Static {
+				target = dot(name, "prototype")
+			}
+			kind := "value"
+			if m.Get {
+				kind = "get"
+			} else if m.Set {
+				kind = "set"
+			}
+			fn := &js.FuncDecl{Params: m.Params, Body: m.Body}
+			body = append(body, &js.ExprStmt{Value: call(t.helper("defineProperty"), target, key, str(kind), fn, boolean(false))})
+		default:
+			// A static field is initialized with the class as this.
+			fn := &js.FuncDecl{Body: js.BlockStmt{List: []js.IStmt{t.field(&e.Field)}}}
+			t.function(&fn.Params, &fn.Body, &funcContext{super: super, static: true})
+			body = append(body, &js.ExprStmt{Value: call(dot(fn, "call"), name)})
+		}
+	}
+	body = append(body, &js.ReturnStmt{Value: name})
+
+	fn := &js.FuncDecl{Params: params, Body: js.BlockStmt{List: body}}
+	t.finishFunction(f, &fn.Body)
+	t.fn = f.parent
+	return call(fn, argsValues(args)...)
+}
+
+// field returns an unconverted statement that initializes a field of this.
+func (t *transpiler) field(f *js.Field) js.IStmt {
+	key := rawKey(t.classKey(&f.Name))
+	init := f.Init
+	if init == nil {
+		init = void0()
+	}
+	return &js.ExprStmt{Value: assign(member(thisExpr(), key), init)}
+}
+
+// classKey returns the property name of a class member. Private members are not supported.
+func (t *transpiler) classKey(n *js.ClassElementName) *js.PropertyName {
+	if n.Private != nil {
+		t.errorf("private class members are not supported")
+	}
+	return &n.PropertyName
+}
+
+func argsValues(args js.Args) []js.IExpr {
+	vs := make([]js.IExpr, 0, len(args.List))
+	for _, a := range args.List {
+		vs = append(vs, a.Value)
+	}
+	return vs
+}
@@ -0,0 +1,566 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+func (t *transpiler) expr(e js.IExpr) js.IExpr {
+	if e == nil {
+		return nil
+	}
+	if hasOptional(e) {
+		return t.optionalChain(e)
+	}
+	switch e := e.(type) {
+	case *js.Var:
+		// #x in o
+		if e.Decl == js.PrivateDecl {
+			t.errorf("private class members are not supported")
+		}
+		if e.Decl == js.NoDecl && string(e.Data) == "arguments" {
+			return t.arguments()
+		}
+	case *js.LiteralExpr:
+		return t.literal(e)
+	case *js.GroupExpr:
+		e.X = t.expr(e.X)
+	case *js.CommaExpr:
+		for i, x := range e.List {
+			e.List[i] = t.expr(x)
+		}
+	case *js.CondExpr:
+		e.Cond = t.expr(e.Cond)
+		e.X = t.expr(e.X)
+		e.Y = t.expr(e.Y)
+	case *js.UnaryExpr:
+		if e.Op == js.AwaitToken {
+			t.errorf("async functions and generators are not supported")
+		}
+		e.X = t.expr(e.X)
+	case *js.BinaryExpr:
+		return t.binary(e)
+	case *js.ArrowFunc:
+		if e.Async {
+			t.errorf("async functions and generators are not supported")
+		}
+		t.function(&e.Params, &e.Body, &funcContext{arrow: true})
+		return &js.FuncDecl{Params: e.Params, Body: e.Body}
+	case *js.FuncDecl:
+		t.funcDecl(e)
+	case *js.ClassDecl:
+		return t.class(e)
+	case *js.ObjectExpr:
+		return t.object(e)
+	case *js.ArrayExpr:
+		if !hasSpread(e.List) {
+			for i := range e.List {
+				e.List[i].Value = t.expr(e.List[i].Value)
+			}
+			return e
+		}
+		return t.spread(e.List, true)
+	case *js.TemplateExpr:
+		return t.template(e)
+	case *js.DotExpr:
+		if isSuper(e.X) {
+			return t.superMember(e)
+		}
+		// this.#x
+		if v, ok := e.Y.(*js.Var); ok && v.Decl == js.PrivateDecl {
+			t.errorf("private class members are not supported")
+		}
+		e.X = t.expr(e.X)
+	case *js.IndexExpr:
+		if isSuper(e.X) {
+			return t.superMember(e)
+		}
+		e.X = t.expr(e.X)
+		e.Y = t.expr(e.Y)
+	case *js.CallExpr:
+		return t.call(e)
+	case *js.NewExpr:
+		e.X = t.expr(e.X)
+		if e.Args == nil {
+			return e
+		}
+		if !hasSpread(argElements(*e.Args)) {
+			t.args(e.Args)
+			return e
+		}
+		return call(t.helper("construct"), e.X, t.spread(argElements(*e.Args), false))
+	case *js.VarDecl:
+		return t.varDecl(e)
+	case *js.YieldExpr:
+		t.errorf("async functions and generators are not supported")
+	case *js.NewTargetExpr:
+		t.errorf("new.target is not supported")
+	case *js.ImportMetaExpr:
+		t.errorf("modules are not supported")
+	}
+	return e
+}
+
+func (t *transpiler) literal(e *js.LiteralExpr) js.IExpr {
+	switch e.TokenType {
+	case js.ThisToken:
+		return t.this()
+	case js.SuperToken:
+		t.errorf("unexpected super")
+	case js.StringToken:
+		e.Data = es5String(e.Data)
+	case js.DecimalToken, js.BinaryToken, js.OctalToken, js.HexadecimalToken, js.IntegerToken:
+		d := bytes.Replace(e.Data, []byte("_"), nil, -1)
+		if bytes.HasSuffix(d, []byte("n")) {
+			t.errorf("BigInt is not supported")
+			return e
+		}
+		if 2 < len(d) && d[0] == '0' {
+			base := 0
+			switch d[1] {
+			case 'b', 'B':
+				base = 2
+			case 'o', 'O':
+				base = 8
+			}
+			if base != 0 {
+				n, err := strconv.ParseUint(string(d[2:]), base, 64)
+				if err != nil {
+					t.errorf("invalid number %s", e.Data)
+					return e
+				}
+				d = []byte(strconv.FormatUint(n, 10))
+			}
+		}
+		e.Data = d
+	}
+	return e
+}
+
+func (t *transpiler) binary(e *js.BinaryExpr) js.IExpr {
+	switch e.Op {
+	case js.EqToken:
+		switch e.X.(type) {
+		case *js.ArrayExpr, *js.ObjectExpr:
+			return t.assignPattern(e.X, t.expr(e.Y))
+		}
+	case js.ExpToken:
+		return call(dot(ident("Math"), "pow"), t.expr(e.X), t.expr(e.Y))
+	case js.ExpEqToken:
+		x := t.expr(e.X)
+		return assign(x, call(dot(ident("Math"), "pow"), x, t.expr(e.Y)))
+	case js.NullishToken:
+		return t.nullish(t.expr(e.X), t.expr(e.Y))
+	case js.NullishEqToken:
+		x := t.expr(e.X)
+		return t.nullish(x, group(assign(x, t.expr(e.Y))))
+	case js.OrEqToken, js.AndEqToken:
+		op := js.OrToken
+		if e.Op == js.AndEqToken {
+			op = js.AndToken
+		}
+		x := t.expr(e.X)
+		return group(binary(op, x, group(assign(x, t.expr(e.Y)))))
+	}
+	e.X = t.expr(e.X)
+	e.Y = t.expr(e.Y)
+	return e
+}
+
+// nullish returns an expression of x ?? y.
+func (t *transpiler) nullish(x, y js.IExpr) js.IExpr {
+	test, value := x, x
+	if !isSimple(x) {
+		r := t.temp("ref")
+		test = group(assign(r, x))
+		value = r
+	}
+	return group(&js.CondExpr{
+		Cond: binary(js.NotEqToken, test, null()),
+		X:    value,
+		Y:    y,
+	})
+}
+
+// chain returns the object and the optional flag of a member, call or tagged template expression.
+func chain(e js.IExpr) (*js.IExpr, *bool) {
+	switch e := e.(type) {
+	case *js.DotExpr:
+		return &e.X, &e.Optional
+	case *js.IndexExpr:
+		return &e.X, &e.Optional
+	case *js.CallExpr:
+		return &e.X, &e.Optional
+	case *js.TemplateExpr:
+		if e.Tag != nil {
+			return &e.Tag, &e.Optional
+		}
+	}
+	return nil, nil
+}
+
+// hasOptional reports whether e is an optional chain like a?.b.
+func hasOptional(e js.IExpr) bool {
+	for {
+		x, opt := chain(e)
+		if x == nil {
+			return false
+		}
+		if *opt {
+			return true
+		}
+		e = *x
+	}
+}
+
+// optionalChain converts the last ?. in e and the rest of the chain recursively.
+func (t *transpiler) optionalChain(e js.IExpr) js.IExpr {
+	slot := &e
+	for {
+		x, opt := chain(*slot)
+		if *opt {
+			*opt = false
+			break
+		}
+		slot = x
+	}
+	n := *slot
+	x, _ := chain(n)
+
+	// a.b?.() calls the function with a as this.
+	if c, ok := n.(*js.CallExpr); ok && isMember(c.X) {
+		mx, mopt := chain(c.X)
+		if !isSuper(*mx) {
+			o, f := t.temp("ref"), t.temp("ref")
+			obj := t.expr(*mx)
+			*mx = o
+			memberOpt := *mopt
+			*mopt = false
+			get := assign(f, t.expr(c.X))
+			*slot = &js.CallExpr{
+				X:    dot(f, "call"),
+				Args: js.Args{List: append([]js.Arg{{Value: o}}, c.Args.List...)},
+			}
+			rest := t.expr(e)
+			if memberOpt {
+				// a?.b?.()
+				rest = &js.CondExpr{
+					Cond: binary(js.EqEqToken, group(get), null()),
+					X:    void0(),
+					Y:    rest,
+				}
+				return group(&js.CondExpr{
+					Cond: binary(js.EqEqToken, group(assign(o, obj)), null()),
+					X:    void0(),
+					Y:    rest,
+				})
+			}
+			return group(&js.CondExpr{
+				Cond: binary(js.EqEqToken, group(&js.CommaExpr{List: []js.IExpr{assign(o, obj), get}}), null()),
+				X:    void0(),
+				Y:    rest,
+			})
+		}
+	}
+
+	r := t.temp("ref")
+	test := group(assign(r, t.expr(*x)))
+	*x = r
+	return group(&js.CondExpr{
+		Cond: binary(js.EqEqToken, test, null()),
+		X:    void0(),
+		Y:    t.expr(e),
+	})
+}
+
+func (t *transpiler) call(e *js.CallExpr) js.IExpr {
+	if isSuper(e.X) {
+		f := t.fn.outer()
+		if f.super == nil || !f.ctor {
+			t.errorf("unexpected super")
+			return e
+		}
+		return t.callWith(f.super, t.this(), e.Args)
+	}
+	if isMember(e.X) {
+		if x, _ := chain(e.X); isSuper(*x) {
+			return t.callWith(t.superMember(e.X), t.this(), e.Args)
+		}
+	}
+	if !hasSpread(argElements(e.Args)) {
+		e.X = t.expr(e.X)
+		if _, ok := e.X.(*js.FuncDecl); ok {
+			e.X = group(e.X)
+		}
+		t.args(&e.Args)
+		return e
+	}
+	if !isMember(e.X) {
+		return call(dot(t.expr(e.X), "apply"), void0(), t.spread(argElements(e.Args), false))
+	}
+	// obj.f(...args) calls f with obj as this.
+	x, _ := chain(e.X)
+	obj := t.expr(*x)
+	this := obj
+	if !isSimple(obj) {
+		r := t.temp("ref")
+		obj = group(assign(r, obj))
+		this = r
+	}
+	*x = obj
+	if idx, ok := e.X.(*js.IndexExpr); ok {
+		idx.Y = t.expr(idx.Y)
+	}
+	return call(dot(e.X, "apply"), this, t.spread(argElements(e.Args), false))
+}
+
+// callWith returns an expression that calls fn with this and args.
+func (t *transpiler) callWith(fn, this js.IExpr, args js.Args) js.IExpr {
+	if hasSpread(argElements(args)) {
+		return call(dot(fn, "apply"), this, t.spread(argElements(args), false))
+	}
+	t.args(&args)
+	return &js.CallExpr{
+		X:    dot(fn, "call"),
+		Args: js.Args{List: append([]js.Arg{{Value: this}}, args.List...)},
+	}
+}
+
+func (t *transpiler) args(args *js.Args) {
+	for i := range args.List {
+		args.List[i].Value = t.expr(args.List[i].Value)
+	}
+}
+
+// superMember converts super.x or super[x] in a class method.
+func (t *transpiler) superMember(e js.IExpr) js.IExpr {
+	f := t.fn.outer()
+	if f.super == nil {
+		t.errorf("unexpected super")
+		return e
+	}
+	var base js.IExpr = f.super
+	if !f.static {
+		base = dot(f.super, "prototype")
+	}
+	switch e := e.(type) {
+	case *js.DotExpr:
+		e.X = base
+	case *js.IndexExpr:
+		e.X = base
+		e.Y = t.expr(e.Y)
+	}
+	return e
+}
+
+// spread returns an array of elems expanding spread elements.
+// If copy is false, the result might be the array that is spread.
+func (t *transpiler) spread(elems []js.Element, copy bool) js.IExpr {
+	var parts []js.IExpr
+	var cur []js.Element
+	for _, e := range elems {
+		if !e.Spread {
+			cur = append(cur, js.Element{Value: t.expr(e.Value)})
+			continue
+		}
+		if cur != nil {
+			parts = append(parts, &js.ArrayExpr{List: cur})
+			cur = nil
+		}
+		parts = append(parts, call(t.helper("toArray"), t.expr(e.Value)))
+	}
+	if cur != nil {
+		parts = append(parts, &js.ArrayExpr{List: cur})
+	}
+	if len(parts) == 1 && !copy {
+		return parts[0]
+	}
+	if a, ok := parts[0].(*js.ArrayExpr); ok {
+		return call(dot(a, "concat"), parts[1:]...)
+	}
+	return call(dot(&js.ArrayExpr{}, "concat"), parts...)
+}
+
+func hasSpread(elems []js.Element) bool {
+	for _, e := range elems {
+		if e.Spread {
+			return true
+		}
+	}
+	return false
+}
+
+func argElements(args js.Args) []js.Element {
+	elems := make([]js.Element, 0, len(args.List))
+	for _, a := range args.List {
+		elems = append(elems, js.Element{Value: a.Value, Spread: a.Rest})
+	}
+	return elems
+}
+
+func (t *transpiler) template(e *js.TemplateExpr) js.IExpr {
+	texts := make([][]byte, 0, len(e.List)+1)
+	for _, p := range e.List {
+		texts = append(texts, templateText(p.Value))
+	}
+	texts = append(texts, templateText(e.Tail))
+
+	if e.Tag != nil {
+		// tag`a${x}b` is tag(strings, x) where strings is ['a', 'b'] with the raw strings.
+		var cooked, raw []js.Element
+		for _, text := range texts {
+			cooked = append(cooked, js.Element{Value: &js.LiteralExpr{TokenType: js.StringToken, Data: templateString(text)}})
+			r := normalizeNewlines(string(text))
+			raw = append(raw, js.Element{Value: str(r)})
+		}
+		tag := t.expr(e.Tag)
+		if needsGroup(tag) {
+			tag = group(tag)
+		}
+		args := []js.Arg{{Value: call(t.helper("template"), &js.ArrayExpr{List: cooked}, &js.ArrayExpr{List: raw})}}
+		for _, p := range e.List {
+			args = append(args, js.Arg{Value: t.expr(p.Expr)})
+		}
+		return &js.CallExpr{X: tag, Args: js.Args{List: args}}
+	}
+
+	// `a${x}b` is 'a' + x + 'b'.
+	var x js.IExpr = &js.LiteralExpr{TokenType: js.StringToken, Data: templateString(texts[0])}
+	if len(e.List) == 0 {
+		return x
+	}
+	for i, p := range e.List {
+		x = binary(js.AddToken, x, group(t.expr(p.Expr)))
+		if text := texts[i+1]; len(text) != 0 {
+			x = binary(js.AddToken, x, &js.LiteralExpr{TokenType: js.StringToken, Data: templateString(text)})
+		}
+	}
+	return group(x)
+}
+
+func (t *transpiler) object(e *js.ObjectExpr) js.IExpr {
+	// Properties from the first spread or computed property are added one by one.
+	dynamic := len(e.List)
+	for i, p := range e.List {
+		if p.Spread || p.Name != nil && p.Name.IsComputed() {
+			dynamic = i
+			break
+		}
+		if m, ok := p.Value.(*js.MethodDecl); ok && m.Name.IsComputed() {
+			dynamic = i
+			break
+		}
+	}
+	for i := range e.List[:dynamic] {
+		p := &e.List[i]
+		if m, ok := p.Value.(*js.MethodDecl); ok {
+			t.method(m)
+			if m.Get || m.Set {
+				continue
+			}
+			name := m.Name.PropertyName
+			p.Name = &name
+			p.Value = &js.FuncDecl{Params: m.Params, Body: m.Body}
+			continue
+		}
+		p.Value = t.expr(p.Value)
+		// {a: a} would be printed as {a}.
+		if v, ok := p.Value.(*js.Var); ok && p.Name.Literal.TokenType == js.IdentifierToken && bytes.Equal(p.Name.Literal.Data, v.Data) {
+			p.Name = &js.PropertyName{Literal: *str(string(v.Data))}
+		}
+	}
+	if dynamic == len(e.List) {
+		return e
+	}
+
+	o := t.temp("obj")
+	list := []js.IExpr{assign(o, &js.ObjectExpr{List: e.List[:dynamic]})}
+	for _, p := range e.List[dynamic:] {
+		if p.Spread {
+			list = append(list, call(t.helper("assign"), o, t.expr(p.Value)))
+			continue
+		}
+		if m, ok := p.Value.(*js.MethodDecl); ok {
+			key := t.propKey(&m.Name.PropertyName)
+			t.method(m)
+			fn := &js.FuncDecl{Params: m.Params, Body: m.Body}
+			switch {
+			case m.Get:
+				list = append(list, call(t.helper("defineProperty"), o, key, str("get"), fn, boolean(true)))
+			case m.Set:
+				list = append(list, call(t.helper("defineProperty"), o, key, str("set"), fn, boolean(true)))
+			default:
+				list = append(list, assign(member(o, key), fn))
+			}
+			continue
+		}
+		key := t.propKey(p.Name)
+		list = append(list, assign(member(o, key), t.expr(p.Value)))
+	}
+	list = append(list, o)
+	return group(&js.CommaExpr{List: list})
+}
+
+// method converts a method of an object literal.
+func (t *transpiler) method(m *js.MethodDecl) {
+	if m.Async || m.Generator {
+		t.errorf("async functions and generators are not supported")
+		return
+	}
+	t.function(&m.Params, &m.Body, &funcContext{})
+}
+
+// propKey returns the key of a property as an expression.
+func (t *transpiler) propKey(n *js.PropertyName) js.IExpr {
+	return t.expr(rawKey(n))
+}
+
+func rawKey(n *js.PropertyName) js.IExpr {
+	if n.IsComputed() {
+		return n.Computed
+	}
+	if js.IsIdentifierName(n.Literal.TokenType) {
+		return str(string(n.Literal.Data))
+	}
+	l := n.Literal
+	return &l
+}
+
+func isSimple(e js.IExpr) bool {
+	switch e := e.(type) {
+	case *js.Var:
+		return true
+	case *js.LiteralExpr:
+		return e.TokenType == js.ThisToken
+	}
+	return false
+}
+
+func isMember(e js.IExpr) bool {
+	switch e.(type) {
+	case *js.DotExpr, *js.IndexExpr:
+		return true
+	}
+	return false
+}
+
+func isSuper(e js.IExpr) bool {
+	l, ok := e.(*js.LiteralExpr)
+	return ok && l.TokenType == js.SuperToken
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+// helperSrcs are the functions that converted scripts call. The names are prefixed with _gophermv_.
+var helperSrcs = map[string]string{
+	"assign": `
+function _gophermv_assign(target, source) {
+    if (source !== null && source !== undefined) {
+        for (var key in source) {
+            if (Object.prototype.hasOwnProperty.call(source, key)) {
+                target[key] = source[key];
+            }
+        }
+    }
+    return target;
+}
+`,
+	"construct": `
+function _gophermv_construct(ctor, args) {
+    return new (Function.prototype.bind.apply(ctor, [null].concat(args)))();
+}
+`,
+	"defineProperty": `
+function _gophermv_defineProperty(target, key, kind, value, enumerable) {
+    var desc = {configurable: true, enumerable: enumerable};
+    if (kind === "value") {
+        desc.value = value;
+        desc.writable = true;
+    } else {
+        var old = Object.getOwnPropertyDescriptor(target, key);
+        if (old && !("value" in old)) {
+            desc.get = old.get;
+            desc.set = old.set;
+        }
+        desc[kind] = value;
+    }
+    Object.defineProperty(target, key, desc);
+}
+`,
+	"inherits": `
+function _gophermv_inherits(ctor, superCtor) {
+    if (typeof superCtor !== "function" && superCtor !== null) {
+        throw new TypeError("Class extends value " + superCtor + " is not a constructor or null");
+    }
+    ctor.prototype = Object.create(superCtor && superCtor.prototype, {
+        constructor: {value: ctor, writable: true, configurable: true}
+    });
+    if (superCtor) {
+        if (Object.setPrototypeOf) {
+            Object.setPrototypeOf(ctor, superCtor);
+        } else {
+            ctor.__proto__ = superCtor;
+        }
+    }
+}
+`,
+	"objectWithout": `
+function _gophermv_objectWithout(obj, keys) {
+    var result = {};
+    for (var i = 0; i < keys.length; i++) {
+        keys[i] = String(keys[i]);
+    }
+    for (var key in obj) {
+        if (Object.prototype.hasOwnProperty.call(obj, key) && keys.indexOf(key) < 0) {
+            result[key] = obj[key];
+        }
+    }
+    return result;
+}
+`,
+	"template": `
+function _gophermv_template(strings, raw) {
+    strings.raw = raw;
+    return strings;
+}
+`,
+	"toArray": `
+function _gophermv_toArray(x) {
+    if (Array.isArray(x)) {
+        return x;
+    }
+    if (typeof x === "string") {
+        return x.match(/[\uD800-\uDBFF][\uDC00-\uDFFF]|[\s\S]/g) || [];
+    }
+    if (typeof Symbol === "function" && x !== null && x !== undefined && typeof x[Symbol.iterator] === "function") {
+        var arr = [];
+        var it = x[Symbol.iterator]();
+        for (var r = it.next(); !r.done; r = it.next()) {
+            arr.push(r.value);
+        }
+        return arr;
+    }
+    if (x !== null && x !== undefined && typeof x.length === "number") {
+        return Array.prototype.slice.call(x);
+    }
+    throw new TypeError(x + " is not iterable");
+}
+`,
+}
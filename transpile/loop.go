@@ -0,0 +1,496 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+import (
+	"github.com/tdewolff/parse/v2/js"
+)
+
+func (t *transpiler) loop(s js.IStmt) js.IStmt {
+	switch s := s.(type) {
+	case *js.ForOfStmt:
+		if s.Await {
+			t.errorf("async functions and generators are not supported")
+			return s
+		}
+		return t.loop(t.forOf(s))
+	case *js.ForStmt:
+		heads := loopVars(s.Init)
+		closure := needsClosure(heads, s.Body)
+		s.Init = t.expr(s.Init)
+		s.Cond = t.expr(s.Cond)
+		s.Post = t.expr(s.Post)
+		s.Body = t.loopBody(s.Body, heads, closure)
+	case *js.ForInStmt:
+		// for (const [a, b] in obj) is converted to for (var k in obj) { const [a, b] = k; }.
+		var first js.IStmt
+		k := t.newVar("key")
+		switch init := s.Init.(type) {
+		case *js.VarDecl:
+			if _, ok := init.List[0].Binding.(*js.Var); !ok {
+				first = &js.VarDecl{
+					TokenType: init.TokenType,
+					List:      []js.BindingElement{{Binding: init.List[0].Binding, Default: k}},
+					Scope:     init.Scope,
+				}
+			}
+		case *js.ArrayExpr, *js.ObjectExpr:
+			first = &js.ExprStmt{Value: &js.BinaryExpr{Op: js.EqToken, X: init, Y: k}}
+		}
+		if first != nil {
+			s.Init = varStmt(k, nil)
+			s.Body.List = append([]js.IStmt{first}, s.Body.List...)
+		}
+		heads := loopVars(s.Init)
+		closure := needsClosure(heads, s.Body)
+		s.Init = t.expr(s.Init)
+		s.Value = t.expr(s.Value)
+		s.Body = t.loopBody(s.Body, heads, closure)
+	case *js.WhileStmt:
+		body := asBlock(s.Body)
+		closure := needsClosure(nil, body)
+		s.Cond = t.expr(s.Cond)
+		s.Body = t.loopBody(body, nil, closure)
+	case *js.DoWhileStmt:
+		body := asBlock(s.Body)
+		closure := needsClosure(nil, body)
+		s.Body = t.loopBody(body, nil, closure)
+		s.Cond = t.expr(s.Cond)
+	}
+	return s
+}
+
+// forOf converts for (x of xs) into for (var i = 0, arr = xs; i < arr.length; i++) { x = arr[i]; }.
+func (t *transpiler) forOf(s *js.ForOfStmt) *js.ForStmt {
+	i := t.newVar("i")
+	arr := t.newVar("arr")
+	item := &js.IndexExpr{X: arr, Y: i}
+	var first js.IStmt
+	if d, ok := s.Init.(*js.VarDecl); ok {
+		first = &js.VarDecl{
+			TokenType: d.TokenType,
+			List:      []js.BindingElement{{Binding: d.List[0].Binding, Default: item}},
+			Scope:     d.Scope,
+		}
+	} else {
+		first = &js.ExprStmt{Value: &js.BinaryExpr{Op: js.EqToken, X: s.Init, Y: item}}
+	}
+	s.Body.List = append([]js.IStmt{first}, s.Body.List...)
+	return &js.ForStmt{
+		Init: &js.VarDecl{
+			TokenType: js.VarToken,
+			List: []js.BindingElement{
+				{Binding: i, Default: num(0)},
+				{Binding: arr, Default: call(t.helper("toArray"), s.Value)},
+			},
+		},
+		Cond: binary(js.LtToken, i, dot(arr, "length")),
+		Post: &js.UnaryExpr{Op: js.PostIncrToken, X: i},
+		Body: s.Body,
+	}
+}
+
+func asBlock(s js.IStmt) *js.BlockStmt {
+	if b, ok := s.(*js.BlockStmt); ok {
+		return b
+	}
+	return &js.BlockStmt{List: []js.IStmt{s}}
+}
+
+// loopVars returns the variables that the initializer of a loop declares by let or const.
+func loopVars(init js.IExpr) []*js.Var {
+	d, ok := init.(*js.VarDecl)
+	if !ok || d.TokenType == js.VarToken {
+		return nil
+	}
+	var vs []*js.Var
+	for _, b := range d.List {
+		vs = bindingVars(b.Binding, vs)
+	}
+	return vs
+}
+
+type visitor func(n js.INode) bool
+
+func (v visitor) Enter(n js.INode) js.IVisitor {
+	if v(n) {
+		return v
+	}
+	return nil
+}
+
+func (v visitor) Exit(n js.INode) {}
+
+func resolve(v *js.Var) *js.Var {
+	for v.Link != nil {
+		v = v.Link
+	}
+	return v
+}
+
+// needsClosure reports whether the body of a loop must be a function called in every iteration,
+// which is the case when closures in the body capture block-scoped variables of the loop.
+// heads are the variables declared by the initializer of the loop.
+func needsClosure(heads []*js.Var, body js.INode) bool {
+	vars := map[*js.Var]bool{}
+	for _, v := range heads {
+		vars[v] = true
+	}
+
+	var closures []js.INode
+	js.Walk(visitor(func(n js.INode) bool {
+		switch n := n.(type) {
+		case *js.FuncDecl, *js.ArrowFunc, *js.MethodDecl:
+			closures = append(closures, n)
+			return false
+		case *js.ClassDecl:
+			if n.Name != nil {
+				vars[n.Name] = true
+			}
+			closures = append(closures, n)
+			return false
+		case *js.VarDecl:
+			if n.TokenType == js.VarToken {
+				return true
+			}
+			for _, b := range n.List {
+				for _, v := range bindingVars(b.Binding, nil) {
+					vars[v] = true
+				}
+			}
+		}
+		return true
+	}), body)
+
+	for _, c := range closures {
+		captured := false
+		js.Walk(visitor(func(n js.INode) bool {
+			if v, ok := n.(*js.Var); ok && vars[resolve(v)] {
+				captured = true
+			}
+			return !captured
+		}), c)
+		if captured {
+			return true
+		}
+	}
+	return false
+}
+
+// assignedVars returns the variables of vs that are assigned in body.
+// Variables that are only read in the left-hand side, like i in a[i] = x, are included too.
+func assignedVars(vs []*js.Var, body js.INode) []*js.Var {
+	targets := map[*js.Var]bool{}
+	for _, v := range vs {
+		targets[v] = false
+	}
+	mark := func(target js.INode) {
+		js.Walk(visitor(func(n js.INode) bool {
+			if v, ok := n.(*js.Var); ok {
+				if _, ok := targets[resolve(v)]; ok {
+					targets[resolve(v)] = true
+				}
+			}
+			return true
+		}), target)
+	}
+	js.Walk(visitor(func(n js.INode) bool {
+		switch n := n.(type) {
+		case *js.BinaryExpr:
+			switch n.Op {
+			case js.EqToken, js.AddEqToken, js.SubEqToken, js.MulEqToken, js.DivEqToken, js.ModEqToken, js.ExpEqToken,
+				js.LtLtEqToken, js.GtGtEqToken, js.GtGtGtEqToken, js.BitAndEqToken, js.BitOrEqToken, js.BitXorEqToken,
+				js.AndEqToken, js.OrEqToken, js.NullishEqToken:
+				mark(n.X)
+			}
+		case *js.UnaryExpr:
+			switch n.Op {
+			case js.PreIncrToken, js.PreDecrToken, js.PostIncrToken, js.PostDecrToken:
+				mark(n.X)
+			}
+		case *js.ForInStmt:
+			mark(n.Init)
+		case *js.ForOfStmt:
+			mark(n.Init)
+		}
+		return true
+	}), body)
+
+	var assigned []*js.Var
+	for _, v := range vs {
+		if targets[v] {
+			assigned = append(assigned, v)
+		}
+	}
+	return assigned
+}
+
+// functionVars returns the variables declared by var in body outside functions.
+func functionVars(body js.INode) map[*js.Var]bool {
+	vars := map[*js.Var]bool{}
+	js.Walk(visitor(func(n js.INode) bool {
+		switch n := n.(type) {
+		case *js.FuncDecl, *js.ArrowFunc, *js.MethodDecl, *js.ClassDecl:
+			return false
+		case *js.VarDecl:
+			if n.TokenType == js.VarToken {
+				for _, b := range n.List {
+					for _, v := range bindingVars(b.Binding, nil) {
+						vars[resolve(v)] = true
+					}
+				}
+			}
+		}
+		return true
+	}), body)
+	return vars
+}
+
+// loopBody converts the body of a loop. If closure is true, the body becomes a function
+// called in every iteration with heads as the arguments.
+//
+// The heads assigned in the function are copied back to the loop after the call.
+// The variables declared by var in the function are declared outside the function so that
+// they are shared among the iterations.
+func (t *transpiler) loopBody(body *js.BlockStmt, heads []*js.Var, closure bool) *js.BlockStmt {
+	if !closure {
+		t.block(body)
+		return body
+	}
+
+	assigned := assignedVars(heads, body)
+	e := &loopExits{
+		vars:   functionVars(body),
+		labels: map[string]bool{},
+	}
+
+	var params js.Params
+	var args js.Args
+	for _, v := range heads {
+		params.List = append(params.List, js.BindingElement{Binding: v})
+		args.List = append(args.List, js.Arg{Value: v})
+	}
+	t.function(&params, body, &funcContext{arrow: true})
+
+	e.rewrite(body, false, false)
+	t.fn.temps = append(t.fn.temps, e.hoisted...)
+
+	// try { body } finally { copy = head; } is called and then head = copy; follows.
+	var after []js.IStmt
+	if len(assigned) > 0 {
+		fin := &js.BlockStmt{}
+		for _, v := range assigned {
+			c := t.temp("copy")
+			fin.List = append(fin.List, &js.ExprStmt{Value: assign(c, v)})
+			after = append(after, &js.ExprStmt{Value: assign(v, c)})
+		}
+		body.List = []js.IStmt{&js.TryStmt{Body: &js.BlockStmt{List: body.List}, Finally: fin}}
+	}
+
+	c := &js.CallExpr{
+		X:    group(&js.FuncDecl{Params: params, Body: *body}),
+		Args: args,
+	}
+	if !e.brk && !e.ret && len(e.jumps) == 0 {
+		return &js.BlockStmt{List: append([]js.IStmt{&js.ExprStmt{Value: c}}, after...)}
+	}
+
+	r := t.temp("ret")
+	list := []js.IStmt{&js.ExprStmt{Value: assign(r, c)}}
+	list = append(list, after...)
+	if e.brk {
+		list = append(list, &js.IfStmt{
+			Cond: binary(js.EqEqEqToken, r, str("break")),
+			Body: &js.BranchStmt{Type: js.BreakToken},
+		})
+	}
+	for _, j := range e.jumps {
+		list = append(list, &js.IfStmt{
+			Cond: binary(js.EqEqEqToken, r, str(branchMarker(j))),
+			Body: j,
+		})
+	}
+	if e.ret {
+		list = append(list, &js.IfStmt{
+			Cond: binary(js.EqEqEqToken, &js.UnaryExpr{Op: js.TypeofToken, X: r}, str("object")),
+			Body: &js.ReturnStmt{Value: dot(r, "v")},
+		})
+	}
+	return &js.BlockStmt{List: list}
+}
+
+// loopExits rewrites the body of a loop that becomes a function.
+//
+// break, continue and return are converted to return with a value telling what to do after the call.
+// var declarations are converted to assignments, and the variables are hoisted.
+type loopExits struct {
+	brk bool
+	ret bool
+
+	// jumps are the break and continue to labels outside the body, which are done after the call.
+	jumps []*js.BranchStmt
+
+	// labels are the labels in the body.
+	labels map[string]bool
+
+	// vars are the variables declared by var in the body.
+	vars    map[*js.Var]bool
+	hoisted []*js.Var
+}
+
+// rewrite rewrites s. inLoop and inSwitch report whether s is in a loop or a switch in the body.
+func (e *loopExits) rewrite(s js.IStmt, inLoop, inSwitch bool) js.IStmt {
+	switch s := s.(type) {
+	case *js.BlockStmt:
+		list := s.List[:0]
+		for _, s2 := range s.List {
+			s2 = e.rewrite(s2, inLoop, inSwitch)
+			if _, ok := s2.(*js.EmptyStmt); ok {
+				continue
+			}
+			list = append(list, s2)
+		}
+		s.List = list
+	case *js.IfStmt:
+		s.Body = e.rewrite(s.Body, inLoop, inSwitch)
+		if s.Else != nil {
+			s.Else = e.rewrite(s.Else, inLoop, inSwitch)
+		}
+	case *js.ForStmt:
+		if d, ok := s.Init.(*js.VarDecl); ok && e.hoist(d) {
+			s.Init = e.assignments(d)
+		}
+		e.rewrite(s.Body, true, inSwitch)
+	case *js.ForInStmt:
+		if d, ok := s.Init.(*js.VarDecl); ok && e.hoist(d) {
+			s.Init = d.List[0].Binding.(*js.Var)
+		}
+		e.rewrite(s.Body, true, inSwitch)
+	case *js.WhileStmt:
+		s.Body = e.rewrite(s.Body, true, inSwitch)
+	case *js.DoWhileStmt:
+		s.Body = e.rewrite(s.Body, true, inSwitch)
+	case *js.SwitchStmt:
+		for i := range s.List {
+			for j, s2 := range s.List[i].List {
+				s.List[i].List[j] = e.rewrite(s2, inLoop, true)
+			}
+		}
+	case *js.LabelledStmt:
+		label := string(s.Label)
+		e.labels[label] = true
+		s.Value = e.rewrite(s.Value, inLoop, inSwitch)
+		delete(e.labels, label)
+	case *js.TryStmt:
+		e.rewrite(s.Body, inLoop, inSwitch)
+		if s.Catch != nil {
+			e.rewrite(s.Catch, inLoop, inSwitch)
+		}
+		if s.Finally != nil {
+			e.rewrite(s.Finally, inLoop, inSwitch)
+		}
+	case *js.WithStmt:
+		s.Body = e.rewrite(s.Body, inLoop, inSwitch)
+	case *js.VarDecl:
+		if !e.hoist(s) {
+			return s
+		}
+		a := e.assignments(s)
+		if a == nil {
+			return &js.EmptyStmt{}
+		}
+		return &js.ExprStmt{Value: a}
+	case *js.ReturnStmt:
+		// return x; is return {v: x}; to return from the outer function.
+		e.ret = true
+		v := s.Value
+		if v == nil {
+			v = void0()
+		}
+		return &js.ReturnStmt{Value: &js.ObjectExpr{List: []js.Property{{Name: &js.PropertyName{Literal: *str("v")}, Value: v}}}}
+	case *js.BranchStmt:
+		if s.Label != nil {
+			if e.labels[string(s.Label)] {
+				return s
+			}
+			// break L; and continue L; are return 'break:L'; and return 'continue:L';.
+			m := branchMarker(s)
+			found := false
+			for _, j := range e.jumps {
+				if branchMarker(j) == m {
+					found = true
+					break
+				}
+			}
+			if !found {
+				e.jumps = append(e.jumps, s)
+			}
+			return &js.ReturnStmt{Value: str(m)}
+		}
+		if s.Type == js.ContinueToken && !inLoop {
+			return &js.ReturnStmt{}
+		}
+		if s.Type == js.BreakToken && !inLoop && !inSwitch {
+			e.brk = true
+			return &js.ReturnStmt{Value: str("break")}
+		}
+	}
+	return s
+}
+
+// hoist reports whether d declares the variables declared by var in the body.
+// If so, the variables are added to the hoisted variables.
+//
+// As d is already converted, d can also declare temporary variables, which are hoisted together.
+func (e *loopExits) hoist(d *js.VarDecl) bool {
+	found := false
+	for _, b := range d.List {
+		if v, ok := b.Binding.(*js.Var); ok && e.vars[resolve(v)] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	for _, b := range d.List {
+		e.hoisted = append(e.hoisted, b.Binding.(*js.Var))
+	}
+	return true
+}
+
+// assignments returns the assignments of the values of d, or nil if d has no values.
+func (e *loopExits) assignments(d *js.VarDecl) js.IExpr {
+	var list []js.IExpr
+	for _, b := range d.List {
+		if b.Default != nil {
+			list = append(list, assign(b.Binding.(*js.Var), b.Default))
+		}
+	}
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	return &js.CommaExpr{List: list}
+}
+
+func branchMarker(s *js.BranchStmt) string {
+	if s.Type == js.BreakToken {
+		return "break:" + string(s.Label)
+	}
+	return "continue:" + string(s.Label)
+}
@@ -0,0 +1,271 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// The printer of the AST doesn't add parentheses, so the functions constructing nodes add them if needed.
+
+func ident(name string) *js.Var {
+	return &js.Var{Data: []byte(name)}
+}
+
+func thisExpr() js.IExpr {
+	return &js.LiteralExpr{TokenType: js.ThisToken, Data: []byte("this")}
+}
+
+// argumentsVar returns arguments of the current function, which is not replaced even in arrow functions.
+func argumentsVar() js.IExpr {
+	return &js.Var{Data: []byte("arguments"), Decl: js.ArgumentDecl}
+}
+
+func null() js.IExpr {
+	return &js.LiteralExpr{TokenType: js.NullToken, Data: []byte("null")}
+}
+
+func boolean(b bool) js.IExpr {
+	if b {
+		return &js.LiteralExpr{TokenType: js.TrueToken, Data: []byte("true")}
+	}
+	return &js.LiteralExpr{TokenType: js.FalseToken, Data: []byte("false")}
+}
+
+func num(n int) js.IExpr {
+	return &js.LiteralExpr{TokenType: js.DecimalToken, Data: []byte(strconv.Itoa(n))}
+}
+
+func void0() js.IExpr {
+	return &js.UnaryExpr{Op: js.VoidToken, X: num(0)}
+}
+
+func str(s string) *js.LiteralExpr {
+	return &js.LiteralExpr{TokenType: js.StringToken, Data: quote(s)}
+}
+
+func group(e js.IExpr) js.IExpr {
+	switch e.(type) {
+	case *js.GroupExpr, *js.Var, *js.LiteralExpr:
+		return e
+	}
+	return &js.GroupExpr{X: e}
+}
+
+// needsGroup reports whether e needs parentheses as the object of a member or call expression.
+func needsGroup(e js.IExpr) bool {
+	switch e := e.(type) {
+	case *js.BinaryExpr, *js.CondExpr, *js.CommaExpr, *js.UnaryExpr, *js.YieldExpr,
+		*js.FuncDecl, *js.ArrowFunc, *js.ClassDecl, *js.ObjectExpr:
+		return true
+	case *js.NewExpr:
+		return e.Args == nil
+	case *js.LiteralExpr:
+		return js.IsNumeric(e.TokenType)
+	}
+	return false
+}
+
+func dot(x js.IExpr, name string) js.IExpr {
+	if needsGroup(x) {
+		x = group(x)
+	}
+	return &js.DotExpr{X: x, Y: js.LiteralExpr{TokenType: js.IdentifierToken, Data: []byte(name)}}
+}
+
+// member returns x[key], or x.key if key is a string of an identifier name.
+func member(x js.IExpr, key js.IExpr) js.IExpr {
+	if l, ok := key.(*js.LiteralExpr); ok && l.TokenType == js.StringToken {
+		name := l.Data[1 : len(l.Data)-1]
+		if bytes.IndexByte(name, '\\') < 0 && js.AsIdentifierName(name) {
+			return dot(x, string(name))
+		}
+	}
+	if needsGroup(x) {
+		x = group(x)
+	}
+	return &js.IndexExpr{X: x, Y: key}
+}
+
+func call(x js.IExpr, args ...js.IExpr) js.IExpr {
+	if needsGroup(x) {
+		x = group(x)
+	}
+	c := &js.CallExpr{X: x}
+	for _, a := range args {
+		if _, ok := a.(*js.CommaExpr); ok {
+			a = group(a)
+		}
+		c.Args.List = append(c.Args.List, js.Arg{Value: a})
+	}
+	return c
+}
+
+func binary(op js.TokenType, x, y js.IExpr) js.IExpr {
+	operand := func(e js.IExpr) js.IExpr {
+		switch e.(type) {
+		case *js.BinaryExpr, *js.CondExpr, *js.CommaExpr, *js.YieldExpr, *js.ArrowFunc:
+			return group(e)
+		}
+		return e
+	}
+	return &js.BinaryExpr{Op: op, X: operand(x), Y: operand(y)}
+}
+
+func assign(x, y js.IExpr) js.IExpr {
+	if _, ok := y.(*js.CommaExpr); ok {
+		y = group(y)
+	}
+	return &js.BinaryExpr{Op: js.EqToken, X: x, Y: y}
+}
+
+func varStmt(b js.IBinding, value js.IExpr) *js.VarDecl {
+	return &js.VarDecl{
+		TokenType: js.VarToken,
+		List:      []js.BindingElement{{Binding: b, Default: value}},
+	}
+}
+
+// quote returns a string literal of s.
+func quote(s string) []byte {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\u2028', '\u2029':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.Bytes()
+}
+
+// es5String converts a string literal to ES5, where \u{...} escapes and raw line separators are not allowed.
+func es5String(s []byte) []byte {
+	if !bytes.Contains(s, []byte(`\u{`)) && !bytes.Contains(s, []byte("\u2028")) && !bytes.Contains(s, []byte("\u2029")) {
+		return s
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch {
+		case bytes.HasPrefix(s[i:], []byte("\u2028")), bytes.HasPrefix(s[i:], []byte("\u2029")):
+			r := rune(0x2028)
+			if s[i+2] == 0xa9 {
+				r = 0x2029
+			}
+			fmt.Fprintf(&b, `\u%04x`, r)
+			i += 2
+		case s[i] == '\\' && i+1 < len(s):
+			if s[i+1] == 'u' && i+2 < len(s) && s[i+2] == '{' {
+				if end := bytes.IndexByte(s[i+3:], '}'); 0 <= end {
+					if c, err := strconv.ParseUint(string(s[i+3:i+3+end]), 16, 32); err == nil {
+						if 0xffff < c {
+							r1, r2 := utf16.EncodeRune(rune(c))
+							fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+						} else {
+							fmt.Fprintf(&b, `\u%04x`, c)
+						}
+						i += 3 + end
+						continue
+					}
+				}
+			}
+			b.WriteByte(s[i])
+			b.WriteByte(s[i+1])
+			i++
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.Bytes()
+}
+
+// templateText returns the raw text of a part of a template literal without the delimiters.
+func templateText(b []byte) []byte {
+	b = b[1:] // ` or }
+	if bytes.HasSuffix(b, []byte("${")) {
+		return b[:len(b)-2]
+	}
+	return b[:len(b)-1] // `
+}
+
+// templateString returns a string literal that has the same value as the raw text of a template.
+func templateString(raw []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch c {
+		case '\\':
+			if len(raw) <= i+1 {
+				break
+			}
+			i++
+			switch raw[i] {
+			case '`', '$':
+				b.WriteByte(raw[i])
+			case '\r':
+				// A line continuation.
+				if i+1 < len(raw) && raw[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+				// A line continuation.
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(raw[i])
+			}
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\n`)
+			if i+1 < len(raw) && raw[i+1] == '\n' {
+				i++
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return es5String(b.Bytes())
+}
+
+// normalizeNewlines replaces CRLF and CR with LF as template literals do.
+func normalizeNewlines(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	return strings.Replace(s, "\r", "\n", -1)
+}
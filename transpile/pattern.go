@@ -0,0 +1,210 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+import (
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// pattern is a destructuring pattern of a declaration or an assignment.
+type pattern struct {
+	// target is the variable or the member expression to assign if the pattern is not an array or an object.
+	target js.IExpr
+
+	array bool
+	elems []patternElem
+	rest  *pattern
+}
+
+type patternElem struct {
+	// key is the key of an element of an object pattern.
+	key js.IExpr
+
+	// pat is nil for a hole of an array pattern.
+	pat *pattern
+	def js.IExpr
+}
+
+// assignment is an assignment that destructuring results in.
+type assignment struct {
+	target js.IExpr
+	value  js.IExpr
+}
+
+// bindingPattern converts the binding of a declaration.
+func (t *transpiler) bindingPattern(b js.IBinding) *pattern {
+	switch b := b.(type) {
+	case *js.BindingArray:
+		p := &pattern{array: true}
+		for _, e := range b.List {
+			if e.Binding == nil {
+				p.elems = append(p.elems, patternElem{})
+				continue
+			}
+			p.elems = append(p.elems, patternElem{
+				pat: t.bindingPattern(e.Binding),
+				def: t.expr(e.Default),
+			})
+		}
+		if b.Rest != nil {
+			p.rest = t.bindingPattern(b.Rest)
+		}
+		return p
+	case *js.BindingObject:
+		p := &pattern{}
+		for _, item := range b.List {
+			var key js.IExpr
+			if item.Key != nil {
+				key = t.propKey(item.Key)
+			} else if v, ok := item.Value.Binding.(*js.Var); ok {
+				key = str(string(v.Data))
+			}
+			p.elems = append(p.elems, patternElem{
+				key: key,
+				pat: t.bindingPattern(item.Value.Binding),
+				def: t.expr(item.Value.Default),
+			})
+		}
+		if b.Rest != nil {
+			p.rest = &pattern{target: b.Rest}
+		}
+		return p
+	}
+	return &pattern{target: b.(*js.Var)}
+}
+
+// exprPattern converts the left-hand side of an assignment.
+func (t *transpiler) exprPattern(e js.IExpr) *pattern {
+	// splitDefault splits a = b in a pattern into the target and the default value.
+	splitDefault := func(e js.IExpr, def js.IExpr) (js.IExpr, js.IExpr) {
+		if b, ok := e.(*js.BinaryExpr); ok && b.Op == js.EqToken {
+			return b.X, t.expr(b.Y)
+		}
+		return e, t.expr(def)
+	}
+	switch e := e.(type) {
+	case *js.ArrayExpr:
+		p := &pattern{array: true}
+		for _, el := range e.List {
+			if el.Spread {
+				p.rest = t.exprPattern(el.Value)
+				continue
+			}
+			if el.Value == nil {
+				p.elems = append(p.elems, patternElem{})
+				continue
+			}
+			target, def := splitDefault(el.Value, nil)
+			p.elems = append(p.elems, patternElem{
+				pat: t.exprPattern(target),
+				def: def,
+			})
+		}
+		return p
+	case *js.ObjectExpr:
+		p := &pattern{}
+		for _, prop := range e.List {
+			if prop.Spread {
+				p.rest = t.exprPattern(prop.Value)
+				continue
+			}
+			target, def := splitDefault(prop.Value, prop.Init)
+			p.elems = append(p.elems, patternElem{
+				key: t.propKey(prop.Name),
+				pat: t.exprPattern(target),
+				def: def,
+			})
+		}
+		return p
+	}
+	return &pattern{target: t.expr(e)}
+}
+
+// destructure appends the assignments that assign value to p to as.
+// If decl is true, the assignments are for a declaration and new variables are declared there.
+func (t *transpiler) destructure(p *pattern, value js.IExpr, decl bool, as *[]assignment) {
+	if p.target != nil {
+		*as = append(*as, assignment{p.target, value})
+		return
+	}
+
+	// Evaluate the value only once.
+	ref := value
+	if p.array {
+		r := t.ref(decl)
+		*as = append(*as, assignment{r, call(t.helper("toArray"), value)})
+		ref = r
+	} else if _, ok := value.(*js.Var); !ok {
+		r := t.ref(decl)
+		*as = append(*as, assignment{r, value})
+		ref = r
+	}
+
+	if p.array {
+		for i, e := range p.elems {
+			if e.pat == nil {
+				continue
+			}
+			t.destructureElem(e, &js.IndexExpr{X: ref, Y: num(i)}, decl, as)
+		}
+		if p.rest != nil {
+			t.destructure(p.rest, call(dot(ref, "slice"), num(len(p.elems))), decl, as)
+		}
+		return
+	}
+
+	var keys []js.Element
+	for _, e := range p.elems {
+		key := e.key
+		if p.rest != nil {
+			if l, ok := key.(*js.LiteralExpr); !ok || l.TokenType == js.ThisToken {
+				r := t.ref(decl)
+				*as = append(*as, assignment{r, key})
+				key = r
+			}
+			keys = append(keys, js.Element{Value: key})
+		}
+		t.destructureElem(e, member(ref, key), decl, as)
+	}
+	if p.rest != nil {
+		t.destructure(p.rest, call(t.helper("objectWithout"), ref, &js.ArrayExpr{List: keys}), decl, as)
+	}
+}
+
+func (t *transpiler) destructureElem(e patternElem, value js.IExpr, decl bool, as *[]assignment) {
+	if e.def != nil {
+		r := t.ref(decl)
+		*as = append(*as, assignment{r, value})
+		value = &js.CondExpr{
+			Cond: binary(js.EqEqEqToken, r, void0()),
+			X:    e.def,
+			Y:    r,
+		}
+	}
+	t.destructure(e.pat, value, decl, as)
+}
+
+// assignPattern converts an assignment to a pattern like [a, b] = [b, a].
+func (t *transpiler) assignPattern(target js.IExpr, value js.IExpr) js.IExpr {
+	r := t.temp("ref")
+	list := []js.IExpr{assign(r, value)}
+	var as []assignment
+	t.destructure(t.exprPattern(target), r, false, &as)
+	for _, a := range as {
+		list = append(list, assign(a.target, a.value))
+	}
+	list = append(list, r)
+	return group(&js.CommaExpr{List: list})
+}
@@ -0,0 +1,425 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transpile converts scripts written in ES2015 and later into ES5 so that Duktape can run them.
+//
+// let and const, arrow functions, classes, template literals, destructuring, default and rest parameters,
+// spread, shorthand, method and computed properties, for-of, exponentiation, optional chaining and
+// nullish coalescing are converted.
+// Generators, async functions, modules and private class members are not supported.
+// Only the syntax is converted: built-in objects and methods added in ES2015 and later like Map and
+// Object.assign are not provided.
+package transpile
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// Version is the version of the conversion. Version changes when Transpile's results change
+// so that cached results can be invalidated.
+const Version = "2"
+
+// Transpile converts the script src into ES5.
+// Comments in src are not preserved.
+func Transpile(src []byte) ([]byte, error) {
+	ast, err := js.Parse(parse.NewInputBytes(src), js.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("transpile: %v", err)
+	}
+	t := &transpiler{
+		helpers: map[string]bool{},
+		tag:     fmt.Sprintf("%08x", crc32.ChecksumIEEE(src)),
+	}
+	f := &funcContext{}
+	t.fn = f
+	t.block(&ast.BlockStmt)
+	t.finishFunction(f, &ast.BlockStmt)
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	out := ast.JSString() + "\n"
+	// Helpers are function declarations and are hoisted. They are put at the end so that
+	// directives like 'use strict' stay at the beginning.
+	names := []string{}
+	for name := range t.helpers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out += "\n" + strings.TrimSpace(helperSrcs[name]) + "\n"
+	}
+	return []byte(out), nil
+}
+
+// SyntaxErrorPosition returns the 1-based line and column of the first syntax error in the script src.
+// ok is false if src has no syntax error. Note that scripts in ES2015 and later are valid.
+func SyntaxErrorPosition(src []byte) (line, column int, ok bool) {
+	_, err := js.Parse(parse.NewInputBytes(src), js.Options{})
+	perr, ok := err.(*parse.Error)
+	if !ok {
+		return 0, 0, false
+	}
+	return perr.Line, perr.Column, true
+}
+
+// funcContext is the state of a function being converted.
+type funcContext struct {
+	parent *funcContext
+
+	// arrow reports whether the function uses this and arguments of the outer function.
+	arrow bool
+
+	thisVar *js.Var
+	argsVar *js.Var
+
+	// temps are the temporary variables declared at the beginning of the function.
+	temps []*js.Var
+
+	// super is the variable of the super class in class methods.
+	super  *js.Var
+	static bool
+	ctor   bool
+}
+
+// outer returns the nearest function that has its own this.
+func (f *funcContext) outer() *funcContext {
+	for f.arrow && f.parent != nil {
+		f = f.parent
+	}
+	return f
+}
+
+type transpiler struct {
+	fn      *funcContext
+	scope   *js.Scope
+	n       int
+	helpers map[string]bool
+	err     error
+
+	// tag is appended to the names of new global variables so that they don't conflict with
+	// the ones of other scripts.
+	tag string
+}
+
+func (t *transpiler) errorf(format string, args ...interface{}) {
+	if t.err != nil {
+		return
+	}
+	t.err = fmt.Errorf("transpile: "+format, args...)
+}
+
+// newVar returns a new variable that doesn't conflict with other variables.
+func (t *transpiler) newVar(base string) *js.Var {
+	return &js.Var{
+		Data: t.newName("_" + base),
+		Decl: js.VariableDecl,
+	}
+}
+
+func (t *transpiler) newName(base string) []byte {
+	t.n++
+	if t.fn.parent == nil {
+		return []byte(fmt.Sprintf("%s$%d_%s", base, t.n, t.tag))
+	}
+	return []byte(fmt.Sprintf("%s$%d", base, t.n))
+}
+
+// temp returns a new variable declared at the beginning of the current function.
+func (t *transpiler) temp(base string) *js.Var {
+	v := t.newVar(base)
+	t.fn.temps = append(t.fn.temps, v)
+	return v
+}
+
+// ref returns a new variable to hold an intermediate value of destructuring.
+// If decl is true, the variable is declared by the declaration that destructures.
+func (t *transpiler) ref(decl bool) *js.Var {
+	if decl {
+		return t.newVar("ref")
+	}
+	return t.temp("ref")
+}
+
+// rename renames a block-scoped variable, which becomes function-scoped, so that it doesn't shadow other variables.
+func (t *transpiler) rename(v *js.Var) {
+	v.Data = t.newName(string(v.Data))
+}
+
+// helper returns the helper function of name and marks it to be output.
+func (t *transpiler) helper(name string) js.IExpr {
+	t.helpers[name] = true
+	return ident("_gophermv_" + name)
+}
+
+func (t *transpiler) this() js.IExpr {
+	if !t.fn.arrow {
+		return thisExpr()
+	}
+	f := t.fn.outer()
+	if f.thisVar == nil {
+		f.thisVar = t.newVar("this")
+	}
+	return f.thisVar
+}
+
+func (t *transpiler) arguments() js.IExpr {
+	f := t.fn.outer()
+	if !t.fn.arrow || f.parent == nil {
+		return argumentsVar()
+	}
+	if f.argsVar == nil {
+		f.argsVar = t.newVar("arguments")
+	}
+	return f.argsVar
+}
+
+// finishFunction declares the variables that the converted function f uses.
+func (t *transpiler) finishFunction(f *funcContext, body *js.BlockStmt) {
+	var list []js.BindingElement
+	if f.thisVar != nil {
+		list = append(list, js.BindingElement{Binding: f.thisVar, Default: thisExpr()})
+	}
+	if f.argsVar != nil {
+		list = append(list, js.BindingElement{Binding: f.argsVar, Default: argumentsVar()})
+	}
+	for _, v := range f.temps {
+		list = append(list, js.BindingElement{Binding: v})
+	}
+	if len(list) == 0 {
+		return
+	}
+	prepend(body, &js.VarDecl{TokenType: js.VarToken, List: list})
+}
+
+// prepend inserts stmts at the beginning of body after the directives like 'use strict'.
+func prepend(body *js.BlockStmt, stmts ...js.IStmt) {
+	if len(stmts) == 0 {
+		return
+	}
+	i := 0
+	for i < len(body.List) {
+		if _, ok := body.List[i].(*js.DirectivePrologueStmt); !ok {
+			break
+		}
+		i++
+	}
+	list := make([]js.IStmt, 0, len(body.List)+len(stmts))
+	list = append(list, body.List[:i]...)
+	list = append(list, stmts...)
+	list = append(list, body.List[i:]...)
+	body.List = list
+}
+
+// function converts the parameters and the body of a function, which is called in the context f.
+func (t *transpiler) function(params *js.Params, body *js.BlockStmt, f *funcContext) {
+	f.parent = t.fn
+	t.fn = f
+
+	var prologue []js.IStmt
+	list := make([]js.BindingElement, 0, len(params.List))
+	for _, p := range params.List {
+		v, ok := p.Binding.(*js.Var)
+		if ok && p.Default == nil {
+			list = append(list, p)
+			continue
+		}
+		if ok {
+			// if (v === void 0) v = default;
+			list = append(list, js.BindingElement{Binding: v})
+			prologue = append(prologue, &js.IfStmt{
+				Cond: binary(js.EqEqEqToken, v, void0()),
+				Body: &js.ExprStmt{Value: assign(v, p.Default)},
+			})
+			continue
+		}
+		r := t.newVar("ref")
+		list = append(list, js.BindingElement{Binding: r})
+		var value js.IExpr = r
+		if p.Default != nil {
+			value = &js.CondExpr{
+				Cond: binary(js.EqEqEqToken, r, void0()),
+				X:    p.Default,
+				Y:    r,
+			}
+		}
+		prologue = append(prologue, varStmt(p.Binding, value))
+	}
+	if params.Rest != nil {
+		slice := dot(dot(dot(ident("Array"), "prototype"), "slice"), "call")
+		prologue = append(prologue, varStmt(params.Rest, call(slice, argumentsVar(), num(len(list)))))
+	}
+	params.List = list
+	params.Rest = nil
+	prepend(body, prologue...)
+
+	t.block(body)
+	t.finishFunction(f, body)
+	t.fn = f.parent
+}
+
+func (t *transpiler) block(b *js.BlockStmt) {
+	scope := t.scope
+	t.scope = &b.Scope
+	for i, s := range b.List {
+		b.List[i] = t.stmt(s)
+	}
+	t.scope = scope
+}
+
+func (t *transpiler) stmt(s js.IStmt) js.IStmt {
+	switch s := s.(type) {
+	case *js.BlockStmt:
+		t.block(s)
+	case *js.ExprStmt:
+		s.Value = t.expr(s.Value)
+		// A statement can't start with 'function' or '{' for an expression.
+		switch s.Value.(type) {
+		case *js.FuncDecl, *js.ObjectExpr:
+			s.Value = group(s.Value)
+		}
+	case *js.VarDecl:
+		return t.varDecl(s)
+	case *js.FuncDecl:
+		t.funcDecl(s)
+	case *js.ClassDecl:
+		if s.Name != nil && t.scope != nil && !isVarScope(t.scope) {
+			t.rename(s.Name)
+		}
+		return varStmt(s.Name, t.class(s))
+	case *js.IfStmt:
+		s.Cond = t.expr(s.Cond)
+		s.Body = t.stmt(s.Body)
+		if s.Else != nil {
+			s.Else = t.stmt(s.Else)
+		}
+	case *js.DoWhileStmt, *js.WhileStmt, *js.ForStmt, *js.ForInStmt, *js.ForOfStmt:
+		return t.loop(s)
+	case *js.SwitchStmt:
+		s.Init = t.expr(s.Init)
+		for i := range s.List {
+			c := &s.List[i]
+			c.Cond = t.expr(c.Cond)
+			for j, s := range c.List {
+				c.List[j] = t.stmt(s)
+			}
+		}
+	case *js.ReturnStmt:
+		s.Value = t.expr(s.Value)
+	case *js.ThrowStmt:
+		s.Value = t.expr(s.Value)
+	case *js.WithStmt:
+		s.Cond = t.expr(s.Cond)
+		s.Body = t.stmt(s.Body)
+	case *js.LabelledStmt:
+		s.Value = t.stmt(s.Value)
+	case *js.TryStmt:
+		t.block(s.Body)
+		if s.Catch != nil {
+			if s.Binding == nil {
+				s.Binding = t.newVar("e")
+			} else if _, ok := s.Binding.(*js.Var); !ok {
+				e := t.newVar("e")
+				prepend(s.Catch, varStmt(s.Binding, e))
+				s.Binding = e
+			}
+			t.block(s.Catch)
+		}
+		if s.Finally != nil {
+			t.block(s.Finally)
+		}
+	case *js.ImportStmt, *js.ExportStmt:
+		t.errorf("modules are not supported")
+	}
+	return s
+}
+
+func (t *transpiler) varDecl(d *js.VarDecl) *js.VarDecl {
+	block := d.TokenType != js.VarToken && d.Scope != nil && !isVarScope(d.Scope)
+	list := make([]js.BindingElement, 0, len(d.List))
+	for _, b := range d.List {
+		if block {
+			for _, v := range bindingVars(b.Binding, nil) {
+				t.rename(v)
+			}
+		}
+		if v, ok := b.Binding.(*js.Var); ok {
+			def := t.expr(b.Default)
+			if def == nil && block && d.TokenType == js.LetToken && !d.InForInOf {
+				// The variable must be reset in every iteration when the block is in a loop.
+				def = void0()
+			}
+			list = append(list, js.BindingElement{Binding: v, Default: def})
+			continue
+		}
+		if b.Default == nil {
+			t.errorf("destructuring without a value is not supported")
+			continue
+		}
+		var as []assignment
+		t.destructure(t.bindingPattern(b.Binding), t.expr(b.Default), true, &as)
+		for _, a := range as {
+			list = append(list, js.BindingElement{Binding: a.target.(*js.Var), Default: a.value})
+		}
+	}
+	d.TokenType = js.VarToken
+	d.List = list
+	return d
+}
+
+func (t *transpiler) funcDecl(f *js.FuncDecl) {
+	if f.Async || f.Generator {
+		t.errorf("async functions and generators are not supported")
+		return
+	}
+	t.function(&f.Params, &f.Body, &funcContext{})
+}
+
+// bindingVars appends the variables that b declares to vs.
+func bindingVars(b js.IBinding, vs []*js.Var) []*js.Var {
+	switch b := b.(type) {
+	case *js.Var:
+		vs = append(vs, b)
+	case *js.BindingArray:
+		for _, e := range b.List {
+			if e.Binding != nil {
+				vs = bindingVars(e.Binding, vs)
+			}
+		}
+		if b.Rest != nil {
+			vs = bindingVars(b.Rest, vs)
+		}
+	case *js.BindingObject:
+		for _, item := range b.List {
+			vs = bindingVars(item.Value.Binding, vs)
+		}
+		if b.Rest != nil {
+			vs = append(vs, b.Rest)
+		}
+	}
+	return vs
+}
+
+// isVarScope reports whether s is the global scope or the scope of a function, where let and const
+// can be var without renaming.
+func isVarScope(s *js.Scope) bool {
+	// Func of the global scope doesn't point to itself since the parser copies the scope.
+	return s.Parent == nil || s.Func == s
+}
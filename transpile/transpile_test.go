@@ -0,0 +1,346 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpile
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/olebedev/go-duktape.v2"
+)
+
+// runES5 runs src in Duktape and returns the lines logged by log.
+func runES5(t *testing.T, src []byte) string {
+	t.Helper()
+	ctx := duktape.New()
+	defer ctx.DestroyHeap()
+	const shim = `var _logs = [];
+function log() {
+  _logs.push(Array.prototype.map.call(arguments, String).join(' '));
+}`
+	if err := ctx.PevalString(shim); err != nil {
+		t.Fatal(err)
+	}
+	ctx.Pop()
+	if err := ctx.PevalString(string(src)); err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	ctx.Pop()
+	if err := ctx.PevalString(`_logs.join('\n')`); err != nil {
+		t.Fatal(err)
+	}
+	return ctx.SafeToString(-1)
+}
+
+func TestTranspile(t *testing.T) {
+	// The expected results are the ones of Node.js.
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "class",
+			src:  "class A { constructor(x) { this.x = x; } get double() { return this.x * 2; } static make() { return new A(3); } }\nlog(A.make().double);",
+			want: "6",
+		},
+		{
+			name: "class extends",
+			src:  "class A { hello() { return 'a'; } }\nclass B extends A { hello() { return super.hello() + 'b'; } }\nlog(new B().hello(), new B() instanceof A);",
+			want: "ab true",
+		},
+		{
+			name: "class fields",
+			src:  "class A { x = 1; static y = 2; }\nclass B extends A { z = this.x + 1; constructor() { super(); this.w = this.z + 1; } }\nlog(new B().w, A.y);",
+			want: "3 2",
+		},
+		{
+			name: "class expression",
+			src:  "const C = class { v() { return 'v'; } };\nlog(new C().v());",
+			want: "v",
+		},
+		{
+			name: "arrow this",
+			src:  "const o = { n: 1, f() { return [1, 2].map(x => x + this.n); } };\nlog(o.f().join());",
+			want: "2,3",
+		},
+		{
+			name: "arrow arguments",
+			src:  "function f() { return (() => arguments.length)(); }\nlog(f(1, 2, 3));",
+			want: "3",
+		},
+		{
+			name: "arrow object",
+			src:  "const f = () => ({ a: 1 });\nlog(f().a);",
+			want: "1",
+		},
+		{
+			name: "default parameters",
+			src:  "function f(a, b = a + 1, c = () => a + b) { return c(); }\nlog(f(1), f(1, 5));",
+			want: "3 6",
+		},
+		{
+			name: "rest parameters",
+			src:  "function f(a, ...rest) { return a + ':' + rest.join(); }\nlog(f(1, 2, 3), f(1));",
+			want: "1:2,3 1:",
+		},
+		{
+			name: "spread",
+			src:  "function f(a, b, c) { return a + b + c; }\nconst xs = [2, 3];\nlog(f(1, ...xs), [0, ...xs, 4].join(), Math.max(...xs));",
+			want: "6 0,2,3,4 3",
+		},
+		{
+			name: "spread new",
+			src:  "function P(a, b) { this.s = a + b; }\nlog(new P(...[1, 2]).s);",
+			want: "3",
+		},
+		{
+			name: "spread object",
+			src:  "const o = { ...{ a: 1, b: 2 }, b: 3, ...null };\nlog(JSON.stringify(o));",
+			want: "{\"a\":1,\"b\":3}",
+		},
+		{
+			name: "array destructuring",
+			src:  "const [a, , b = 5, ...c] = [1, 2, undefined, 4, 5];\nlog(a, b, c.join());",
+			want: "1 5 4,5",
+		},
+		{
+			name: "object destructuring",
+			src:  "const { a, b: { c }, d = 4, ...rest } = { a: 1, b: { c: 3 }, e: 5 };\nlog(a, c, d, JSON.stringify(rest));",
+			want: "1 3 4 {\"e\":5}",
+		},
+		{
+			name: "destructuring assignment",
+			src:  "let a = 1, b = 2;\n[a, b] = [b, a];\nlog(a, b);",
+			want: "2 1",
+		},
+		{
+			name: "destructuring parameters",
+			src:  "function f({ x, y = 2 }, [z]) { return x + y + z; }\nlog(f({ x: 1 }, [3]));",
+			want: "6",
+		},
+		{
+			name: "template literal",
+			src:  "const n = 2;\nlog(`a${n}b${n * 2}`, `x\ny`.length);",
+			want: "a2b4 3",
+		},
+		{
+			name: "tagged template",
+			src:  "function tag(s, ...v) { return s.raw.join('|') + v.join(); }\nlog(tag`a${1}\\n${2}`);",
+			want: "a|\\n|1,2",
+		},
+		{
+			name: "let in block",
+			src:  "let a = 1;\n{ let a = 2; log(a); }\nlog(a);",
+			want: "2\n1",
+		},
+		{
+			name: "let in loop",
+			src:  "const fs = [];\nfor (let i = 0; i < 3; i++) { fs.push(() => i); }\nlog(fs.map(f => f()).join());",
+			want: "0,1,2",
+		},
+		{
+			name: "let reset in loop",
+			src:  "for (let i = 0; i < 2; i++) { let u; if (i === 0) u = 'set'; log(u); }",
+			want: "set\nundefined",
+		},
+		{
+			name: "const in loop body",
+			src:  "const fs = [];\nlet k = 0;\nwhile (k < 3) { const j = k++; fs.push(() => j); }\nlog(fs.map(f => f()).join());",
+			want: "0,1,2",
+		},
+		{
+			name: "loop variable assigned in body",
+			src:  "const fs = [];\nfor (let i = 0; i < 4; i++) { fs.push(() => i); if (i == 1) i++; }\nlog(fs.map(f => f()).join());",
+			want: "0,2,3",
+		},
+		{
+			name: "labelled continue in loop",
+			src:  "const fs = [];\nouter: for (let i = 0; i < 2; i++) { for (let j = 0; j < 2; j++) { fs.push(() => i * 10 + j); continue outer; } }\nlog(fs.map(f => f()).join());",
+			want: "0,10",
+		},
+		{
+			name: "labelled break in loop",
+			src:  "const fs = [];\nblk: { for (let i = 0; i < 5; i++) { fs.push(() => i); if (i === 2) break blk; } log('unreachable'); }\nlog(fs.map(f => f()).join());",
+			want: "0,1,2",
+		},
+		{
+			name: "var in loop",
+			src:  "const fs = [];\nfor (let i = 0; i < 3; i++) { var sum = (sum || 0) + i; fs.push(() => i); }\nlog(fs.map(f => f()).join(), sum);",
+			want: "0,1,2 3",
+		},
+		{
+			name: "return in loop",
+			src:  "function f() { for (let i = 0; i < 5; i++) { const g = () => i; if (i === 3) return g(); } }\nlog(f());",
+			want: "3",
+		},
+		{
+			name: "break and continue in loop",
+			src:  "const fs = [];\nfor (let i = 0; i < 5; i++) { if (i === 1) continue; if (i === 3) break; fs.push(() => i); }\nlog(fs.map(f => f()).join());",
+			want: "0,2",
+		},
+		{
+			name: "for-of",
+			src:  "const r = [];\nfor (const [a, b] of [[1, 2], [3, 4]]) r.push(a + b);\nfor (const c of 'xy') r.push(c);\nlog(r.join());",
+			want: "3,7,x,y",
+		},
+		{
+			name: "for-of closure",
+			src:  "const fs = [];\nfor (const x of ['a', 'b']) { fs.push(() => x); }\nlog(fs.map(f => f()).join());",
+			want: "a,b",
+		},
+		{
+			name: "for-in",
+			src:  "const fs = [];\nfor (const k in { a: 1, b: 2 }) { fs.push(() => k); }\nlog(fs.map(f => f()).join());",
+			want: "a,b",
+		},
+		{
+			name: "optional chaining",
+			src:  "const o = { a: { f: () => 1 } };\nlog(o?.a?.f?.(), o.b?.c.d, o.b?.());",
+			want: "1 undefined undefined",
+		},
+		{
+			name: "nullish",
+			src:  "const o = { a: 0 };\no.b ??= 2;\nlog(o.a ?? 1, null ?? 'n', o.b);",
+			want: "0 n 2",
+		},
+		{
+			name: "exponent",
+			src:  "let x = 2;\nx **= 3;\nlog(x, 2 ** 3 ** 2);",
+			want: "8 512",
+		},
+		{
+			name: "shorthand and computed properties",
+			src:  "const a = 1, k = 'b';\nconst o = { a, [k + 1]: 2, m() { return this.a; } };\nlog(JSON.stringify(o), o.m());",
+			want: "{\"a\":1,\"b1\":2} 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Transpile([]byte(tc.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := runES5(t, out); got != tc.want {
+				t.Errorf("got %q, want %q\n%s", got, tc.want, out)
+			}
+		})
+	}
+}
+
+func TestTranspileError(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{
+			name: "async",
+			src:  "async function f() {}",
+			err:  "async functions and generators are not supported",
+		},
+		{
+			name: "generator",
+			src:  "function* g() { yield 1; }",
+			err:  "async functions and generators are not supported",
+		},
+		{
+			name: "for await",
+			src:  "async function f() { for await (const x of xs) {} }",
+			err:  "async functions and generators are not supported",
+		},
+		{
+			name: "module",
+			src:  "import x from 'x';",
+			err:  "modules are not supported",
+		},
+		{
+			name: "export",
+			src:  "export const x = 1;",
+			err:  "modules are not supported",
+		},
+		{
+			name: "private member",
+			src:  "class A { #x = 1; }",
+			err:  "private class members are not supported",
+		},
+		{
+			name: "private method",
+			src:  "class A { #m() {} }",
+			err:  "private class members are not supported",
+		},
+		{
+			name: "private member access",
+			src:  "class A { #x; m() { return this.#x; } }",
+			err:  "private class members are not supported",
+		},
+		{
+			name: "private member in",
+			src:  "class A { #x; static has(o) { return #x in o; } }",
+			err:  "private class members are not supported",
+		},
+		{
+			name: "new.target",
+			src:  "function F() { return new.target; }",
+			err:  "new.target is not supported",
+		},
+		{
+			name: "BigInt",
+			src:  "const n = 1n;",
+			err:  "BigInt is not supported",
+		},
+		{
+			name: "field before super",
+			src:  "class A {}\nclass B extends A { x = 1; constructor() { if (true) { super(); } } }",
+			err:  "class fields need super() at the top level of the constructor",
+		},
+		{
+			name: "syntax error",
+			src:  "let = ;",
+			err:  "transpile: ",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Transpile([]byte(tc.src))
+			if err == nil {
+				t.Fatal("got nil, want an error")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("got %q, want an error containing %q", err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	testCases := []struct {
+		src    string
+		line   int
+		column int
+		ok     bool
+	}{
+		{"var a = 1;", 0, 0, false},
+		{"let f = (x) => x;", 0, 0, false},
+		{"var a = 1;\nvar b = (1 +;\n", 2, 13, true},
+		{"var s = 'あ';\r\n  }", 2, 3, true},
+	}
+	for _, tc := range testCases {
+		line, column, ok := SyntaxErrorPosition([]byte(tc.src))
+		if line != tc.line || column != tc.column || ok != tc.ok {
+			t.Errorf("SyntaxErrorPosition(%q): got %d, %d, %t, want %d, %d, %t", tc.src, line, column, ok, tc.line, tc.column, tc.ok)
+		}
+	}
+}